@@ -0,0 +1,245 @@
+package hexaring
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// servicePrefix is the method prefix of the hexaring services.  Only these methods
+// are subject to auth so a server shared with the chord transport is unaffected.
+const servicePrefix = "/hexaring."
+
+const (
+	authHeader      = "authorization"
+	hmacIDHeader    = "x-hexaring-id"
+	hmacTimeHeader  = "x-hexaring-ts"
+	hmacSigHeader   = "x-hexaring-sig"
+	bearerPrefix    = "Bearer "
+	defaultHMACSkew = 5 * time.Minute
+)
+
+// Authenticator verifies the credentials of an incoming request returning the caller
+// identity
+type Authenticator interface {
+	Authenticate(ctx context.Context, method string) (string, error)
+}
+
+// Signer adds credentials to an outgoing request
+type Signer interface {
+	Sign(ctx context.Context, method string) (context.Context, error)
+}
+
+// Authorizer decides whether the caller identity may call the method.  Returning an
+// error denies the call.
+type Authorizer func(ctx context.Context, identity, method string) error
+
+// AuthConfig contains the hexaring services auth settings.  A nil Authenticator allows
+// anonymous callers whose identity is empty.
+type AuthConfig struct {
+	Authenticator Authenticator
+	Authorizer    Authorizer
+}
+
+type identityKey struct{}
+
+// CallerIdentity returns the authenticated caller identity of a request handled by a
+// hexaring service
+func CallerIdentity(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(string)
+	return id, ok
+}
+
+// authorize authenticates and authorizes the request returning the context with the
+// caller identity
+func (conf *AuthConfig) authorize(ctx context.Context, method string) (context.Context, error) {
+	var id string
+	if conf.Authenticator != nil {
+		var err error
+		if id, err = conf.Authenticator.Authenticate(ctx, method); err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "%v", err)
+		}
+	}
+
+	if conf.Authorizer != nil {
+		if err := conf.Authorizer(ctx, id, method); err != nil {
+			return nil, status.Errorf(codes.PermissionDenied, "%v", err)
+		}
+	}
+
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+// UnaryServerInterceptor returns the interceptor enforcing auth on unary calls
+func (conf *AuthConfig) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, servicePrefix) {
+			return handler(ctx, req)
+		}
+		actx, err := conf.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(actx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor enforcing auth on streaming calls
+func (conf *AuthConfig) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, servicePrefix) {
+			return handler(srv, ss)
+		}
+		actx, err := conf.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: actx})
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// unarySignInterceptor signs each outgoing unary call
+func unarySignInterceptor(signer Signer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		sctx, err := signer.Sign(ctx, method)
+		if err != nil {
+			return err
+		}
+		return invoker(sctx, method, req, reply, cc, opts...)
+	}
+}
+
+// streamSignInterceptor signs each outgoing stream
+func streamSignInterceptor(signer Signer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		sctx, err := signer.Sign(ctx, method)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(sctx, desc, cc, method, opts...)
+	}
+}
+
+// StaticTokenAuth authenticates callers by bearer token
+type StaticTokenAuth struct {
+	// Token to identity
+	Tokens map[string]string
+}
+
+// Authenticate satisfies the Authenticator interface
+func (a *StaticTokenAuth) Authenticate(ctx context.Context, method string) (string, error) {
+	tok := firstMD(ctx, authHeader)
+	if !strings.HasPrefix(tok, bearerPrefix) {
+		return "", fmt.Errorf("bearer token required")
+	}
+	tok = strings.TrimPrefix(tok, bearerPrefix)
+
+	for t, id := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(tok)) == 1 {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("invalid token")
+}
+
+// StaticTokenSigner sends a bearer token with each request
+type StaticTokenSigner string
+
+// Sign satisfies the Signer interface
+func (token StaticTokenSigner) Sign(ctx context.Context, method string) (context.Context, error) {
+	return metadata.AppendToOutgoingContext(ctx, authHeader, bearerPrefix+string(token)), nil
+}
+
+// HMACAuth authenticates callers by a HMAC-SHA256 signature of their identity, a
+// timestamp and the method called using a key shared with the caller.  The signature
+// does not cover the request body, use TLS for integrity.
+type HMACAuth struct {
+	// Identity to shared key
+	Keys map[string][]byte
+	// Max difference between the request timestamp and local time.  Defaults to 5m
+	MaxSkew time.Duration
+}
+
+// Authenticate satisfies the Authenticator interface
+func (a *HMACAuth) Authenticate(ctx context.Context, method string) (string, error) {
+	id := firstMD(ctx, hmacIDHeader)
+	key, ok := a.Keys[id]
+	if !ok {
+		return "", fmt.Errorf("unknown identity: %q", id)
+	}
+
+	tsv := firstMD(ctx, hmacTimeHeader)
+	ts, err := strconv.ParseInt(tsv, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp: %q", tsv)
+	}
+
+	skew := a.MaxSkew
+	if skew <= 0 {
+		skew = defaultHMACSkew
+	}
+	if d := time.Since(time.Unix(0, ts)); d > skew || d < -skew {
+		return "", fmt.Errorf("timestamp out of range: %v", d)
+	}
+
+	sig, err := hex.DecodeString(firstMD(ctx, hmacSigHeader))
+	if err != nil || !hmac.Equal(sig, hmacSign(key, id, tsv, method)) {
+		return "", fmt.Errorf("invalid signature")
+	}
+	return id, nil
+}
+
+// HMACSigner signs each request with a key shared with the server
+type HMACSigner struct {
+	ID  string
+	Key []byte
+}
+
+// Sign satisfies the Signer interface
+func (s *HMACSigner) Sign(ctx context.Context, method string) (context.Context, error) {
+	ts := strconv.FormatInt(time.Now().UnixNano(), 10)
+	sig := hex.EncodeToString(hmacSign(s.Key, s.ID, ts, method))
+	return metadata.AppendToOutgoingContext(ctx,
+		hmacIDHeader, s.ID,
+		hmacTimeHeader, ts,
+		hmacSigHeader, sig,
+	), nil
+}
+
+func hmacSign(key []byte, id, ts, method string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "\n" + ts + "\n" + method))
+	return mac.Sum(nil)
+}
+
+// firstMD returns the first incoming metadata value for the key
+func firstMD(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
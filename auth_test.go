@@ -0,0 +1,184 @@
+package hexaring
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// signedContext returns an incoming context with the metadata added by the signer
+func signedContext(t *testing.T, signer Signer, method string) context.Context {
+	ctx, err := signer.Sign(context.Background(), method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestStaticTokenAuth(t *testing.T) {
+	auth := &StaticTokenAuth{Tokens: map[string]string{"secret": "tenant1"}}
+
+	id, err := auth.Authenticate(signedContext(t, StaticTokenSigner("secret"), "m"), "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "tenant1" {
+		t.Fatal("wrong identity", id)
+	}
+
+	if _, err = auth.Authenticate(signedContext(t, StaticTokenSigner("bad"), "m"), "m"); err == nil {
+		t.Fatal("should fail with bad token")
+	}
+	if _, err = auth.Authenticate(context.Background(), "m"); err == nil {
+		t.Fatal("should fail without token")
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	auth := &HMACAuth{Keys: map[string][]byte{"tenant1": []byte("key1")}}
+	signer := &HMACSigner{ID: "tenant1", Key: []byte("key1")}
+
+	id, err := auth.Authenticate(signedContext(t, signer, "/a"), "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "tenant1" {
+		t.Fatal("wrong identity", id)
+	}
+
+	// Signed for another method
+	if _, err = auth.Authenticate(signedContext(t, signer, "/a"), "/b"); err == nil {
+		t.Fatal("should fail with wrong method")
+	}
+	// Wrong key
+	bad := &HMACSigner{ID: "tenant1", Key: []byte("key2")}
+	if _, err = auth.Authenticate(signedContext(t, bad, "/a"), "/a"); err == nil {
+		t.Fatal("should fail with wrong key")
+	}
+	// Unknown identity
+	unknown := &HMACSigner{ID: "tenant2", Key: []byte("key1")}
+	if _, err = auth.Authenticate(signedContext(t, unknown, "/a"), "/a"); err == nil {
+		t.Fatal("should fail with unknown identity")
+	}
+	// Stale
+	auth.MaxSkew = time.Nanosecond
+	ctx := signedContext(t, signer, "/a")
+	<-time.After(time.Millisecond)
+	if _, err = auth.Authenticate(ctx, "/a"); err == nil {
+		t.Fatal("should fail with old timestamp")
+	}
+}
+
+func TestAuthConfig_Interceptor(t *testing.T) {
+	conf := &AuthConfig{
+		Authenticator: &StaticTokenAuth{Tokens: map[string]string{"t1": "tenant1", "t2": "guest"}},
+		Authorizer: func(ctx context.Context, id, method string) error {
+			if id == "guest" {
+				return fmt.Errorf("denied")
+			}
+			return nil
+		},
+	}
+	icpt := conf.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id, _ := CallerIdentity(ctx)
+		return id, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: servicePrefix + "LookupRPC/LookupRPC"}
+
+	resp, err := icpt(signedContext(t, StaticTokenSigner("t1"), ""), nil, info, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.(string) != "tenant1" {
+		t.Fatal("identity not set", resp)
+	}
+
+	_, err = icpt(signedContext(t, StaticTokenSigner("t2"), ""), nil, info, handler)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatal("should be denied", err)
+	}
+	_, err = icpt(context.Background(), nil, info, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatal("should be unauthenticated", err)
+	}
+
+	// Other services are not checked
+	other := &grpc.UnaryServerInfo{FullMethod: "/chord.chordGRPC/ListVnodesServe"}
+	if _, err = icpt(context.Background(), nil, other, handler); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetClient_Auth(t *testing.T) {
+	r, err := initTestRing("127.0.0.1:16545")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.conf.Auth = &AuthConfig{
+		Authenticator: &HMACAuth{Keys: map[string][]byte{
+			"tenant1": []byte("key1"),
+			"guest":   []byte("key2"),
+		}},
+		Authorizer: func(ctx context.Context, id, method string) error {
+			if id == "guest" {
+				return fmt.Errorf("%s may not call %s", id, method)
+			}
+			return nil
+		},
+	}
+	opts, err := r.ServerOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:16546")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	r.RegisterLookupServer(server)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	client := NewNetClient(2*time.Second, 10*time.Second, WithSigner(&HMACSigner{ID: "tenant1", Key: []byte("key1")}))
+	defer client.Shutdown()
+	locs, err := client.LookupReplicated("127.0.0.1:16546", testkey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 {
+		t.Fatal("should have 1 location")
+	}
+
+	guest := NewNetClient(2*time.Second, 10*time.Second, WithSigner(&HMACSigner{ID: "guest", Key: []byte("key2")}))
+	defer guest.Shutdown()
+	_, err = guest.LookupReplicated("127.0.0.1:16546", testkey, 1)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatal("should be denied", err)
+	}
+	// Streams are checked too
+	it, err := guest.ScourReplica("127.0.0.1:16546", testkey)
+	if err == nil {
+		_, err = it.Next()
+		it.Close()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatal("stream should be denied", err)
+	}
+
+	anon := NewNetClient(2*time.Second, 10*time.Second)
+	defer anon.Shutdown()
+	_, err = anon.LookupReplicated("127.0.0.1:16546", testkey, 1)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatal("should be unauthenticated", err)
+	}
+}
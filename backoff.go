@@ -0,0 +1,71 @@
+package hexaring
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff implements a retry policy
+type Backoff interface {
+	// Next returns the time to wait before retrying after the given failed attempt.
+	// Attempts start at 1.  It returns false if no further attempts should be made
+	Next(attempt int) (time.Duration, bool)
+}
+
+// ExponentialBackoff implements a capped exponential Backoff with jitter
+type ExponentialBackoff struct {
+	// Wait time before the first retry
+	Initial time.Duration
+	// Max wait time between retries
+	Max time.Duration
+	// Factor by which the wait time grows after each attempt
+	Multiplier float64
+	// Fraction of the wait time to randomly add or subtract e.g. 0.2 for +/- 20%
+	Jitter float64
+	// Max number of attempts including the first.  Zero means no limit
+	MaxAttempts int
+}
+
+// Next returns the jittered exponential wait time after the failed attempt capped to
+// Max.  The wait after the first attempt is Initial.
+func (b *ExponentialBackoff) Next(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d), true
+}
+
+// Validate checks the backoff parameters
+func (b *ExponentialBackoff) Validate() error {
+	if b.Initial <= 0 {
+		return fmt.Errorf("backoff initial must be > 0: %v", b.Initial)
+	}
+	if b.Max > 0 && b.Max < b.Initial {
+		return fmt.Errorf("backoff max must be >= initial: %v < %v", b.Max, b.Initial)
+	}
+	if b.Multiplier < 1 {
+		return fmt.Errorf("backoff multiplier must be >= 1: %v", b.Multiplier)
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		return fmt.Errorf("backoff jitter must be between 0 and 1: %v", b.Jitter)
+	}
+	if b.MaxAttempts < 0 {
+		return fmt.Errorf("backoff max attempts must be >= 0: %d", b.MaxAttempts)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package hexaring
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := &ExponentialBackoff{
+		Initial:     time.Second,
+		Max:         10 * time.Second,
+		Multiplier:  2,
+		MaxAttempts: 7,
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, e := range expected {
+		d, ok := b.Next(i + 1)
+		if !ok {
+			t.Fatal("should retry after attempt", i+1)
+		}
+		if d != e*time.Second {
+			t.Fatal("wrong backoff", i+1, d)
+		}
+	}
+
+	if _, ok := b.Next(7); ok {
+		t.Fatal("should not retry after the last attempt")
+	}
+
+	b.Jitter = 0.5
+	b.MaxAttempts = 0
+	for i := 0; i < 100; i++ {
+		d, ok := b.Next(3)
+		if !ok {
+			t.Fatal("should allow unlimited attempts")
+		}
+		if d < 2*time.Second || d > 6*time.Second {
+			t.Fatal("jitter out of range", d)
+		}
+	}
+
+	b.Multiplier = 0.5
+	if err := b.Validate(); err == nil {
+		t.Fatal("should fail with multiplier < 1")
+	}
+}
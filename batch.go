@@ -0,0 +1,115 @@
+package hexaring
+
+import (
+	"bytes"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+type arc struct {
+	start []byte
+	end   []byte
+	vns   []*chord.Vnode
+}
+
+// arcMemo memoizes successor lookups for a batch.  A lookup of hash h returning the
+// successors s0..sn means every hash in [h, s0] has the same successors, so lookups of
+// hashes that fall in an already resolved arc are served without another chord lookup.
+type arcMemo struct {
+	lookup func(ctx context.Context, hash []byte) ([]*chord.Vnode, error)
+
+	mu   sync.Mutex
+	arcs []arc
+	// number of chord lookups performed
+	lookups int
+}
+
+func newArcMemo(lookup func(ctx context.Context, hash []byte) ([]*chord.Vnode, error)) *arcMemo {
+	return &arcMemo{lookup: lookup}
+}
+
+// successors satisfies SuccessorLookup serving from resolved arcs where possible
+func (m *arcMemo) successors(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+	m.mu.Lock()
+	for _, a := range m.arcs {
+		if inArc(a.start, a.end, hash) {
+			m.mu.Unlock()
+			return a.vns, nil
+		}
+	}
+	m.lookups++
+	m.mu.Unlock()
+
+	vns, err := m.lookup(ctx, hash)
+	if err != nil || len(vns) == 0 {
+		return vns, err
+	}
+
+	m.mu.Lock()
+	m.arcs = append(m.arcs, arc{start: hash, end: vns[0].Id, vns: vns})
+	m.mu.Unlock()
+
+	return vns, nil
+}
+
+// inArc returns true if the key is in [start, end] going clockwise around the ring
+func inArc(start, end, key []byte) bool {
+	s := bytes.Compare(start, key)
+	e := bytes.Compare(key, end)
+
+	switch bytes.Compare(start, end) {
+	case 0:
+		return s == 0
+	case -1:
+		return s <= 0 && e <= 0
+	default:
+		// Wraps around zero
+		return s <= 0 || e <= 0
+	}
+}
+
+// lookupReplicatedHashBatch returns the replica locations for each hash sharing
+// successor lookups between hashes.  The returned errors are per hash.  The lookup
+// cache is used as in LookupReplicatedHashCtx.
+func (r *Ring) lookupReplicatedHashBatch(ctx context.Context, hashes [][]byte, n int) ([]LocationSet, []error) {
+	var (
+		memo = newArcMemo(r.successors)
+		out  = make([]LocationSet, len(hashes))
+		errs = make([]error, len(hashes))
+	)
+
+	for i, h := range hashes {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		out[i], errs[i] = r.lookupReplicatedHash(ctx, memo.successors, h, n)
+	}
+
+	return out, errs
+}
+
+// lookupHashBatch returns n successors for each hash sharing lookups between hashes.
+// The returned errors are per hash.
+func (r *Ring) lookupHashBatch(ctx context.Context, hashes [][]byte, n int) ([][]*chord.Vnode, []error) {
+	var (
+		memo = newArcMemo(func(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+			return r.lookupHash(ctx, n, hash)
+		})
+		out  = make([][]*chord.Vnode, len(hashes))
+		errs = make([]error, len(hashes))
+	)
+
+	for i, h := range hashes {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		out[i], errs[i] = memo.successors(ctx, h)
+	}
+
+	return out, errs
+}
@@ -0,0 +1,70 @@
+package hexaring
+
+import (
+	"crypto/sha1"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestInArc(t *testing.T) {
+	cases := []struct {
+		start, end, key byte
+		in              bool
+	}{
+		{10, 20, 10, true},
+		{10, 20, 15, true},
+		{10, 20, 20, true},
+		{10, 20, 21, false},
+		{10, 20, 9, false},
+		// Wrap around
+		{250, 5, 255, true},
+		{250, 5, 0, true},
+		{250, 5, 5, true},
+		{250, 5, 100, false},
+		{10, 10, 10, true},
+		{10, 10, 11, false},
+	}
+
+	for _, c := range cases {
+		if inArc([]byte{c.start}, []byte{c.end}, []byte{c.key}) != c.in {
+			t.Fatalf("wrong result start=%d end=%d key=%d", c.start, c.end, c.key)
+		}
+	}
+}
+
+func TestArcMemo(t *testing.T) {
+	lookup := testRingLookup(testRingVnodes("h1", "h2", "h3"), 8)
+	memo := newArcMemo(lookup)
+
+	var (
+		ctx    = context.Background()
+		hashes [][]byte
+	)
+	for i := 0; i < 200; i++ {
+		sh := sha1.Sum([]byte{byte(i)})
+		hashes = append(hashes, sh[:])
+	}
+
+	p := NewEquidistantPlacement()
+	for _, h := range hashes {
+		expected, err := p.Place(ctx, lookup, h, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs, err := p.Place(ctx, memo.successors, h, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range expected {
+			if expected[i].Vnode != locs[i].Vnode || expected[i].Index != locs[i].Index {
+				t.Fatal("memoized placement mismatch")
+			}
+		}
+	}
+
+	// 600 vertexes over 12 vnodes should mostly be served from resolved arcs
+	if memo.lookups > 100 {
+		t.Fatal("lookups not shared", memo.lookups)
+	}
+}
@@ -0,0 +1,145 @@
+package hexaring
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// LookupCacheConfig contains the options for caching replicated lookup results
+type LookupCacheConfig struct {
+	// Max number of cached location sets.  Zero disables the cache
+	Size int
+	// Time a cached location set is valid for
+	TTL time.Duration
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context that bypasses the lookup cache for calls made with it.
+// The result of the lookup is not cached either.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func cacheDisabled(ctx context.Context) bool {
+	v, _ := ctx.Value(noCacheKey{}).(bool)
+	return v
+}
+
+type cacheEntry struct {
+	key     string
+	locs    LocationSet
+	expires time.Time
+}
+
+// lookupCache is a bounded LRU cache of location sets keyed by hash and replica count
+type lookupCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+	// Incremented on each purge
+	gen uint64
+}
+
+func newLookupCache(size int, ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func cacheKey(hash []byte, n int) string {
+	return strconv.Itoa(n) + ":" + string(hash)
+}
+
+// get returns a copy of the cached location set if it exists and has not expired
+func (c *lookupCache) get(hash []byte, n int) (LocationSet, bool) {
+	key := cacheKey(hash, n)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	ent := el.Value.(*cacheEntry)
+	if time.Now().After(ent.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return copyLocationSet(ent.locs), true
+}
+
+// generation returns the current purge generation.  It is captured before a lookup and
+// passed to set so results looked up before a purge are not cached after it.
+func (c *lookupCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// set adds a copy of the location set evicting the least recently used if full.  It is
+// not added if the cache was purged since the generation was captured.
+func (c *lookupCache) set(gen uint64, hash []byte, n int, locs LocationSet) {
+	key := cacheKey(hash, n)
+	ent := &cacheEntry{key: key, locs: copyLocationSet(locs), expires: time.Now().Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value = ent
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(ent)
+	if c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).key)
+	}
+}
+
+// purge removes all entries
+func (c *lookupCache) purge() {
+	c.mu.Lock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element, c.size)
+	c.gen++
+	c.mu.Unlock()
+}
+
+func (c *lookupCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// copyLocationSet copies each location so callers cannot modify cached data.  Vnodes
+// are shared.
+func copyLocationSet(locs LocationSet) LocationSet {
+	out := make(LocationSet, len(locs))
+	for i, l := range locs {
+		cp := *l
+		out[i] = &cp
+	}
+	return out
+}
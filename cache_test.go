@@ -0,0 +1,112 @@
+package hexaring
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func testLocationSet(hosts ...string) LocationSet {
+	locs := make(LocationSet, len(hosts))
+	for i, h := range hosts {
+		locs[i] = &Location{ID: []byte(h), Priority: int32(i), Vnode: &chord.Vnode{Host: h}}
+	}
+	return locs
+}
+
+func TestLookupCache(t *testing.T) {
+	c := newLookupCache(2, time.Minute)
+
+	c.set(c.generation(), []byte("a"), 2, testLocationSet("h1", "h2"))
+	c.set(c.generation(), []byte("b"), 2, testLocationSet("h2", "h3"))
+
+	locs, ok := c.get([]byte("a"), 2)
+	if !ok {
+		t.Fatal("should be cached")
+	}
+	if locs.String() != "[ h1 h2 ]" {
+		t.Fatal("wrong locations", locs)
+	}
+	// Cached copy must not change
+	locs[0].Priority = 10
+	locs, _ = c.get([]byte("a"), 2)
+	if locs[0].Priority != 0 {
+		t.Fatal("cached location modified")
+	}
+
+	if _, ok = c.get([]byte("a"), 3); ok {
+		t.Fatal("replica count should be part of the key")
+	}
+
+	// b is the least recently used
+	c.set(c.generation(), []byte("c"), 2, testLocationSet("h3", "h4"))
+	if _, ok = c.get([]byte("b"), 2); ok {
+		t.Fatal("b should be evicted")
+	}
+	if c.len() != 2 {
+		t.Fatal("should have 2 entries")
+	}
+
+	c.purge()
+	if c.len() != 0 {
+		t.Fatal("should be empty")
+	}
+
+	// Looked up before the purge
+	gen := c.generation()
+	c.purge()
+	c.set(gen, []byte("a"), 2, testLocationSet("h1", "h2"))
+	if c.len() != 0 {
+		t.Fatal("stale result should not be cached after a purge")
+	}
+
+	c = newLookupCache(2, time.Millisecond)
+	c.set(c.generation(), []byte("a"), 2, testLocationSet("h1", "h2"))
+	<-time.After(5 * time.Millisecond)
+	if _, ok = c.get([]byte("a"), 2); ok {
+		t.Fatal("should be expired")
+	}
+}
+
+func TestRing_LookupCache(t *testing.T) {
+	conf := fastConf("127.0.0.1:47778")
+	conf.LookupCache = LookupCacheConfig{Size: 10, TTL: time.Minute}
+
+	r := New(conf, NewInMemPeerStore())
+	if r.conf.Delegate != r.delegate {
+		t.Fatal("delegate should be installed")
+	}
+	if conf.Delegate != nil {
+		t.Fatal("caller config should not be modified")
+	}
+
+	hash := []byte("hash")
+	r.cache.set(r.cache.generation(), hash, 2, testLocationSet("h1", "h2"))
+
+	locs, err := r.LookupReplicatedHashCtx(context.Background(), hash, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locs.String() != "[ h1 h2 ]" {
+		t.Fatal("should be served from cache", locs)
+	}
+
+	if !cacheDisabled(WithoutCache(context.Background())) {
+		t.Fatal("cache should be disabled")
+	}
+
+	// Neighborhood change invalidates the cache
+	r.delegate.NewPredecessor(&chord.Vnode{}, &chord.Vnode{}, &chord.Vnode{})
+	if r.cache.len() != 0 {
+		t.Fatal("cache should be purged")
+	}
+
+	r.cache.set(r.cache.generation(), hash, 2, testLocationSet("h1", "h2"))
+	r.InvalidateCache()
+	if r.cache.len() != 0 {
+		t.Fatal("cache should be purged")
+	}
+}
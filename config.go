@@ -0,0 +1,464 @@
+package hexaring
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// LookupServiceConfig contains the options for the network lookup service served to
+// clients by the NetTransport
+type LookupServiceConfig struct {
+	// Replica count used when a request does not specify one
+	DefaultReplicas int
+	// Max replicas a client may request.  Requests for more are rejected
+	MaxReplicas int
+	// Max keys in a batch request.  Zero means no limit
+	MaxBatchKeys int
+}
+
+// Config contains the configuration options for the chord ring.  It augments the
+// default chord configuration with the transport, join and lookup settings
+type Config struct {
+	*chord.Config
+
+	// Chord grpc transport settings
+	RPCTimeout  time.Duration
+	MaxConnIdle time.Duration
+
+	// Time to wait before trying the next peer when joining
+	JoinPeerDelay time.Duration
+	// Backoff policy used by RetryJoin once all peers have been tried
+	JoinBackoff Backoff
+	// Overall time allowed for RetryJoin.  Zero means no limit
+	RetryJoinTimeout time.Duration
+
+	// Meta keys holding the failure domain labels of a node from the widest to the
+	// narrowest e.g. zone then rack.  Replicas are spread across these before hosts.
+	// This applies to the default placement
+	FailureDomains []string
+	// Meta key holding the capacity weight of a node e.g. "2" for twice the default
+	// capacity.  Replicas are skewed towards heavier nodes.  This applies to the default
+	// placement
+	WeightKey string
+	// Replica placement strategy.  Defaults to the equidistant placement
+	Placement PlacementStrategy
+
+	// Replicated lookup cache options.  Disabled by default
+	LookupCache LookupCacheConfig
+
+	// Lookup service options
+	LookupService LookupServiceConfig
+
+	// TLS settings for the lookup service.  Nil serves and dials in plaintext.  The chord
+	// transport is not covered, see Ring.ServerOptions
+	Security *SecurityConfig
+	// Authentication and authorization of lookup service callers.  Nil allows all
+	Auth *AuthConfig
+
+	// Receives lookup, scour, join and lookup service measurements.  Nil disables them
+	Metrics Metrics
+	// Creates spans for lookups and lookup service requests.  Nil disables tracing
+	Tracer Tracer
+	// Receives structured logs from the ring, placement and TLS reloads.  Nil logs to the
+	// slog default logger
+	Logger Logger
+}
+
+// DefaultConfig returns a sane config
+func DefaultConfig(hostname string) *Config {
+	cfg := &Config{
+		Config:        chord.DefaultConfig(hostname),
+		RPCTimeout:    3 * time.Second,
+		MaxConnIdle:   5 * time.Minute,
+		JoinPeerDelay: 500 * time.Millisecond,
+		JoinBackoff: &ExponentialBackoff{
+			Initial:    2 * time.Second,
+			Max:        2 * time.Minute,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+		LookupService: LookupServiceConfig{
+			DefaultReplicas: 2,
+			MaxReplicas:     0, // defaults to NumSuccessors
+			MaxBatchKeys:    1000,
+		},
+	}
+	cfg.NumVnodes = 5                  // lowered from 8
+	cfg.StabilizeMin = 3 * time.Second // lowered from 15
+	cfg.StabilizeMax = 7 * time.Second // lowered from 45
+	//cfg.StabilizeThresh = 30 * time.Second // enables adaptive stabilization
+	return cfg
+}
+
+// Validate checks the config for invalid values returning the first error found
+func (conf *Config) Validate() error {
+	if conf.Config == nil {
+		return fmt.Errorf("chord config required")
+	}
+	if conf.Hostname == "" {
+		return fmt.Errorf("hostname required")
+	}
+	if conf.HashFunc == nil {
+		return fmt.Errorf("hash function required")
+	}
+	if conf.NumVnodes < 1 {
+		return fmt.Errorf("NumVnodes must be > 0: %d", conf.NumVnodes)
+	}
+	if conf.NumSuccessors < 1 {
+		return fmt.Errorf("NumSuccessors must be > 0: %d", conf.NumSuccessors)
+	}
+	if conf.StabilizeMin <= 0 || conf.StabilizeMax < conf.StabilizeMin {
+		return fmt.Errorf("invalid stabilize range: %v - %v", conf.StabilizeMin, conf.StabilizeMax)
+	}
+	if conf.RPCTimeout <= 0 {
+		return fmt.Errorf("RPCTimeout must be > 0: %v", conf.RPCTimeout)
+	}
+	if conf.MaxConnIdle <= 0 {
+		return fmt.Errorf("MaxConnIdle must be > 0: %v", conf.MaxConnIdle)
+	}
+	if conf.JoinPeerDelay < 0 {
+		return fmt.Errorf("JoinPeerDelay must be >= 0: %v", conf.JoinPeerDelay)
+	}
+	if conf.RetryJoinTimeout < 0 {
+		return fmt.Errorf("RetryJoinTimeout must be >= 0: %v", conf.RetryJoinTimeout)
+	}
+	if conf.JoinBackoff == nil {
+		return fmt.Errorf("JoinBackoff required")
+	}
+	if v, ok := conf.JoinBackoff.(interface {
+		Validate() error
+	}); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, d := range conf.FailureDomains {
+		if d == "" {
+			return fmt.Errorf("empty failure domain key")
+		}
+	}
+
+	if conf.LookupCache.Size < 0 {
+		return fmt.Errorf("LookupCache.Size must be >= 0: %d", conf.LookupCache.Size)
+	}
+	if conf.LookupCache.Size > 0 && conf.LookupCache.TTL <= 0 {
+		return fmt.Errorf("LookupCache.TTL must be > 0: %v", conf.LookupCache.TTL)
+	}
+
+	ls := conf.LookupService
+	if ls.MaxReplicas < 0 || ls.MaxReplicas > conf.NumSuccessors {
+		return fmt.Errorf("LookupService.MaxReplicas must be between 0 and NumSuccessors (%d): %d",
+			conf.NumSuccessors, ls.MaxReplicas)
+	}
+	if ls.MaxBatchKeys < 0 {
+		return fmt.Errorf("LookupService.MaxBatchKeys must be >= 0: %d", ls.MaxBatchKeys)
+	}
+	if ls.DefaultReplicas < 1 || ls.DefaultReplicas > conf.maxReplicas() {
+		return fmt.Errorf("LookupService.DefaultReplicas must be between 1 and %d: %d",
+			conf.maxReplicas(), ls.DefaultReplicas)
+	}
+
+	if conf.Security != nil {
+		if err := conf.Security.Validate(); err != nil {
+			return fmt.Errorf("Security: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// maxReplicas returns the max replicas a lookup may request
+func (conf *Config) maxReplicas() int {
+	if conf.LookupService.MaxReplicas > 0 {
+		return conf.LookupService.MaxReplicas
+	}
+	return conf.NumSuccessors
+}
+
+// duration is a time.Duration that decodes from a json string such as "3s"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err == nil {
+		*d = duration(v)
+	}
+	return err
+}
+
+// jsonConfig is the json representation of a Config.  Only fields present in the json
+// are applied
+type jsonConfig struct {
+	Hostname         *string
+	NumVnodes        *int
+	NumSuccessors    *int
+	StabilizeMin     *duration
+	StabilizeMax     *duration
+	StabilizeThresh  *duration
+	Meta             map[string]string
+	FailureDomains   []string
+	WeightKey        *string
+	RPCTimeout       *duration
+	MaxConnIdle      *duration
+	JoinPeerDelay    *duration
+	RetryJoinTimeout *duration
+	JoinBackoff      *struct {
+		Initial     *duration
+		Max         *duration
+		Multiplier  *float64
+		Jitter      *float64
+		MaxAttempts *int
+	}
+	LookupCache *struct {
+		Size *int
+		TTL  *duration
+	}
+	LookupService *struct {
+		DefaultReplicas *int
+		MaxReplicas     *int
+		MaxBatchKeys    *int
+	}
+	Security *struct {
+		CertFile       *string
+		KeyFile        *string
+		CAFile         *string
+		ClientAuth     *bool
+		ServerName     *string
+		ReloadInterval *duration
+	}
+}
+
+// LoadJSON reads json from the reader overwriting the config values present.  Durations
+// are specified as strings e.g. "3s".  The config is not validated but must embed a
+// chord config.
+func (conf *Config) LoadJSON(r io.Reader) error {
+	if conf.Config == nil {
+		return fmt.Errorf("chord config required")
+	}
+
+	var jc jsonConfig
+	if err := json.NewDecoder(r).Decode(&jc); err != nil {
+		return err
+	}
+
+	if jc.Hostname != nil {
+		conf.Hostname = *jc.Hostname
+	}
+	if jc.NumVnodes != nil {
+		conf.NumVnodes = *jc.NumVnodes
+	}
+	if jc.NumSuccessors != nil {
+		conf.NumSuccessors = *jc.NumSuccessors
+	}
+	if jc.StabilizeMin != nil {
+		conf.StabilizeMin = time.Duration(*jc.StabilizeMin)
+	}
+	if jc.StabilizeMax != nil {
+		conf.StabilizeMax = time.Duration(*jc.StabilizeMax)
+	}
+	if jc.StabilizeThresh != nil {
+		conf.StabilizeThresh = time.Duration(*jc.StabilizeThresh)
+	}
+	if jc.Meta != nil {
+		conf.Meta = chord.Meta{}
+		for k, v := range jc.Meta {
+			conf.Meta[k] = []byte(v)
+		}
+	}
+	if jc.FailureDomains != nil {
+		conf.FailureDomains = jc.FailureDomains
+	}
+	if jc.WeightKey != nil {
+		conf.WeightKey = *jc.WeightKey
+	}
+	if jc.RPCTimeout != nil {
+		conf.RPCTimeout = time.Duration(*jc.RPCTimeout)
+	}
+	if jc.MaxConnIdle != nil {
+		conf.MaxConnIdle = time.Duration(*jc.MaxConnIdle)
+	}
+	if jc.JoinPeerDelay != nil {
+		conf.JoinPeerDelay = time.Duration(*jc.JoinPeerDelay)
+	}
+	if jc.RetryJoinTimeout != nil {
+		conf.RetryJoinTimeout = time.Duration(*jc.RetryJoinTimeout)
+	}
+	if jb := jc.JoinBackoff; jb != nil {
+		// Only the built-in backoff can be configured.  Start from the current values
+		// if it is already in use
+		eb, ok := conf.JoinBackoff.(*ExponentialBackoff)
+		if !ok {
+			eb = &ExponentialBackoff{Multiplier: 1}
+			conf.JoinBackoff = eb
+		}
+		if jb.Initial != nil {
+			eb.Initial = time.Duration(*jb.Initial)
+		}
+		if jb.Max != nil {
+			eb.Max = time.Duration(*jb.Max)
+		}
+		if jb.Multiplier != nil {
+			eb.Multiplier = *jb.Multiplier
+		}
+		if jb.Jitter != nil {
+			eb.Jitter = *jb.Jitter
+		}
+		if jb.MaxAttempts != nil {
+			eb.MaxAttempts = *jb.MaxAttempts
+		}
+	}
+	if lc := jc.LookupCache; lc != nil {
+		if lc.Size != nil {
+			conf.LookupCache.Size = *lc.Size
+		}
+		if lc.TTL != nil {
+			conf.LookupCache.TTL = time.Duration(*lc.TTL)
+		}
+	}
+	if ls := jc.LookupService; ls != nil {
+		if ls.DefaultReplicas != nil {
+			conf.LookupService.DefaultReplicas = *ls.DefaultReplicas
+		}
+		if ls.MaxReplicas != nil {
+			conf.LookupService.MaxReplicas = *ls.MaxReplicas
+		}
+		if ls.MaxBatchKeys != nil {
+			conf.LookupService.MaxBatchKeys = *ls.MaxBatchKeys
+		}
+	}
+	if sc := jc.Security; sc != nil {
+		if conf.Security == nil {
+			conf.Security = &SecurityConfig{}
+		}
+		if sc.CertFile != nil {
+			conf.Security.CertFile = *sc.CertFile
+		}
+		if sc.KeyFile != nil {
+			conf.Security.KeyFile = *sc.KeyFile
+		}
+		if sc.CAFile != nil {
+			conf.Security.CAFile = *sc.CAFile
+		}
+		if sc.ClientAuth != nil {
+			conf.Security.ClientAuth = *sc.ClientAuth
+		}
+		if sc.ServerName != nil {
+			conf.Security.ServerName = *sc.ServerName
+		}
+		if sc.ReloadInterval != nil {
+			conf.Security.ReloadInterval = time.Duration(*sc.ReloadInterval)
+		}
+	}
+
+	return nil
+}
+
+// LoadEnv overwrites config values with those set in the environment.  Each variable
+// name is the prefix followed by the upper case option e.g. HEXARING_NUM_VNODES.  All
+// variables are parsed before any is applied so the config is unchanged on error.  The
+// config is not validated but must embed a chord config.
+func (conf *Config) LoadEnv(prefix string) error {
+	if conf.Config == nil {
+		return fmt.Errorf("chord config required")
+	}
+
+	strs := map[string]*string{
+		"HOSTNAME":   &conf.Hostname,
+		"WEIGHT_KEY": &conf.WeightKey,
+	}
+	ints := map[string]*int{
+		"NUM_VNODES":                      &conf.NumVnodes,
+		"NUM_SUCCESSORS":                  &conf.NumSuccessors,
+		"LOOKUP_CACHE_SIZE":               &conf.LookupCache.Size,
+		"LOOKUP_SERVICE_DEFAULT_REPLICAS": &conf.LookupService.DefaultReplicas,
+		"LOOKUP_SERVICE_MAX_REPLICAS":     &conf.LookupService.MaxReplicas,
+		"LOOKUP_SERVICE_MAX_BATCH_KEYS":   &conf.LookupService.MaxBatchKeys,
+	}
+	durs := map[string]*time.Duration{
+		"STABILIZE_MIN":      &conf.StabilizeMin,
+		"STABILIZE_MAX":      &conf.StabilizeMax,
+		"STABILIZE_THRESH":   &conf.StabilizeThresh,
+		"RPC_TIMEOUT":        &conf.RPCTimeout,
+		"MAX_CONN_IDLE":      &conf.MaxConnIdle,
+		"JOIN_PEER_DELAY":    &conf.JoinPeerDelay,
+		"RETRY_JOIN_TIMEOUT": &conf.RetryJoinTimeout,
+		"LOOKUP_CACHE_TTL":   &conf.LookupCache.TTL,
+	}
+	floats := map[string]*float64{}
+	// Only the built-in backoff can be configured from the environment
+	if eb, ok := conf.JoinBackoff.(*ExponentialBackoff); ok {
+		durs["JOIN_BACKOFF_INITIAL"] = &eb.Initial
+		durs["JOIN_BACKOFF_MAX"] = &eb.Max
+		floats["JOIN_BACKOFF_MULTIPLIER"] = &eb.Multiplier
+		floats["JOIN_BACKOFF_JITTER"] = &eb.Jitter
+		ints["JOIN_BACKOFF_MAX_ATTEMPTS"] = &eb.MaxAttempts
+	}
+
+	// Values are applied once all have been parsed
+	var sets []func()
+
+	if v, ok := os.LookupEnv(prefix + "FAILURE_DOMAINS"); ok {
+		// Comma separated list of meta keys
+		var domains []string
+		for _, d := range strings.Split(v, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				domains = append(domains, d)
+			}
+		}
+		sets = append(sets, func() { conf.FailureDomains = domains })
+	}
+
+	for k, p := range strs {
+		if v, ok := os.LookupEnv(prefix + k); ok {
+			p := p
+			sets = append(sets, func() { *p = v })
+		}
+	}
+	for k, p := range ints {
+		if v, ok := os.LookupEnv(prefix + k); ok {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", prefix, k, err)
+			}
+			p := p
+			sets = append(sets, func() { *p = i })
+		}
+	}
+	for k, p := range floats {
+		if v, ok := os.LookupEnv(prefix + k); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", prefix, k, err)
+			}
+			p := p
+			sets = append(sets, func() { *p = f })
+		}
+	}
+	for k, p := range durs {
+		if v, ok := os.LookupEnv(prefix + k); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", prefix, k, err)
+			}
+			p := p
+			sets = append(sets, func() { *p = d })
+		}
+	}
+
+	for _, set := range sets {
+		set()
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package hexaring
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	conf := DefaultConfig("127.0.0.1:1234")
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.NumVnodes = 0
+	if err := conf.Validate(); err == nil {
+		t.Fatal("should fail with zero vnodes")
+	}
+
+	conf = DefaultConfig("127.0.0.1:1234")
+	conf.LookupService.DefaultReplicas = conf.NumSuccessors + 1
+	if err := conf.Validate(); err == nil {
+		t.Fatal("should fail with replicas > successors")
+	}
+
+	conf = DefaultConfig("127.0.0.1:1234")
+	conf.LookupService.MaxReplicas = conf.NumSuccessors + 1
+	if err := conf.Validate(); err == nil {
+		t.Fatal("should fail with max replicas > successors")
+	}
+
+	conf = DefaultConfig("")
+	if err := conf.Validate(); err == nil {
+		t.Fatal("should fail without hostname")
+	}
+
+	conf = DefaultConfig("127.0.0.1:1234")
+	conf.StabilizeMax = conf.StabilizeMin - 1
+	if err := conf.Validate(); err == nil {
+		t.Fatal("should fail with invalid stabilize range")
+	}
+}
+
+func TestConfig_LoadJSON(t *testing.T) {
+	conf := DefaultConfig("127.0.0.1:1234")
+	data := `{"NumVnodes": 3, "RPCTimeout": "10s", "Meta": {"zone": "a"}, "LookupService": {"DefaultReplicas": 3}, "JoinBackoff": {"MaxAttempts": 5}}`
+	if err := conf.LoadJSON(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.NumVnodes != 3 {
+		t.Fatal("wrong vnode count", conf.NumVnodes)
+	}
+	if conf.RPCTimeout != 10*time.Second {
+		t.Fatal("wrong rpc timeout", conf.RPCTimeout)
+	}
+	if string(conf.Meta["zone"]) != "a" {
+		t.Fatal("wrong meta")
+	}
+	if conf.LookupService.DefaultReplicas != 3 {
+		t.Fatal("wrong default replicas")
+	}
+	if eb := conf.JoinBackoff.(*ExponentialBackoff); eb.MaxAttempts != 5 || eb.Initial != 2*time.Second {
+		t.Fatal("wrong join backoff", eb)
+	}
+	// Untouched
+	if conf.Hostname != "127.0.0.1:1234" || conf.MaxConnIdle != 5*time.Minute {
+		t.Fatal("should not be changed")
+	}
+
+	if err := conf.LoadJSON(strings.NewReader(`{"RPCTimeout": "bad"}`)); err == nil {
+		t.Fatal("should fail with bad duration")
+	}
+	if err := (&Config{}).LoadJSON(strings.NewReader(data)); err == nil {
+		t.Fatal("should fail without a chord config")
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	os.Setenv("HEXARINGTEST_NUM_VNODES", "7")
+	os.Setenv("HEXARINGTEST_MAX_CONN_IDLE", "1m")
+	defer os.Unsetenv("HEXARINGTEST_NUM_VNODES")
+	defer os.Unsetenv("HEXARINGTEST_MAX_CONN_IDLE")
+
+	conf := DefaultConfig("127.0.0.1:1234")
+	if err := conf.LoadEnv("HEXARINGTEST_"); err != nil {
+		t.Fatal(err)
+	}
+	if conf.NumVnodes != 7 {
+		t.Fatal("wrong vnode count", conf.NumVnodes)
+	}
+	if conf.MaxConnIdle != time.Minute {
+		t.Fatal("wrong max idle", conf.MaxConnIdle)
+	}
+
+	// Nothing is applied on error
+	os.Setenv("HEXARINGTEST_NUM_VNODES", "9")
+	os.Setenv("HEXARINGTEST_NUM_SUCCESSORS", "x")
+	defer os.Unsetenv("HEXARINGTEST_NUM_SUCCESSORS")
+	if err := conf.LoadEnv("HEXARINGTEST_"); err == nil {
+		t.Fatal("should fail")
+	}
+	if conf.NumVnodes != 7 {
+		t.Fatal("should not apply values on error", conf.NumVnodes)
+	}
+
+	if err := (&Config{}).LoadEnv("HEXARINGTEST_"); err == nil {
+		t.Fatal("should fail without a chord config")
+	}
+}
@@ -0,0 +1,86 @@
+package hexaring
+
+import (
+	"sync"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// ringDelegate is installed as the chord delegate.  It calls the user supplied delegate,
+// if any, and notifies hexaring listeners of changes to the local neighborhood i.e. a
+// new predecessor or a predecessor or successor leaving.
+type ringDelegate struct {
+	user chord.Delegate
+
+	mu        sync.RWMutex
+	listeners []func()
+	// Called with the arguments of each NewPredecessor
+	predListeners []func(local, remoteNew, remotePrev *chord.Vnode)
+}
+
+func newRingDelegate(user chord.Delegate) *ringDelegate {
+	return &ringDelegate{user: user}
+}
+
+// register adds a function to be called on each neighborhood change
+func (d *ringDelegate) register(f func()) {
+	d.mu.Lock()
+	d.listeners = append(d.listeners, f)
+	d.mu.Unlock()
+}
+
+// registerPredecessor adds a function to be called on each new predecessor
+func (d *ringDelegate) registerPredecessor(f func(local, remoteNew, remotePrev *chord.Vnode)) {
+	d.mu.Lock()
+	d.predListeners = append(d.predListeners, f)
+	d.mu.Unlock()
+}
+
+func (d *ringDelegate) notify() {
+	d.mu.RLock()
+	for _, f := range d.listeners {
+		f()
+	}
+	d.mu.RUnlock()
+}
+
+func (d *ringDelegate) NewPredecessor(local, remoteNew, remotePrev *chord.Vnode) {
+	if d.user != nil {
+		d.user.NewPredecessor(local, remoteNew, remotePrev)
+	}
+
+	d.mu.RLock()
+	for _, f := range d.predListeners {
+		f(local, remoteNew, remotePrev)
+	}
+	d.mu.RUnlock()
+
+	d.notify()
+}
+
+func (d *ringDelegate) Leaving(local, pred, succ *chord.Vnode) {
+	if d.user != nil {
+		d.user.Leaving(local, pred, succ)
+	}
+	d.notify()
+}
+
+func (d *ringDelegate) PredecessorLeaving(local, remote *chord.Vnode) {
+	if d.user != nil {
+		d.user.PredecessorLeaving(local, remote)
+	}
+	d.notify()
+}
+
+func (d *ringDelegate) SuccessorLeaving(local, remote *chord.Vnode) {
+	if d.user != nil {
+		d.user.SuccessorLeaving(local, remote)
+	}
+	d.notify()
+}
+
+func (d *ringDelegate) Shutdown() {
+	if d.user != nil {
+		d.user.Shutdown()
+	}
+}
@@ -0,0 +1,345 @@
+package hexaring
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// Hint is data destined for a replica location whose host was unreachable.  It is held
+// by a fallback vnode until the target host is reachable again.
+type Hint struct {
+	ID string
+	// Host the data belongs to
+	Target string
+	// Location id on the target
+	LocationID []byte
+	// Vnode holding the data in the meantime
+	Fallback *chord.Vnode
+	// Opaque data supplied by the application
+	Payload []byte
+	Created time.Time
+	// Number of failed replay attempts
+	Attempts int
+}
+
+// clone returns a deep copy of the hint
+func (h *Hint) clone() *Hint {
+	cp := *h
+	cp.LocationID = append([]byte(nil), h.LocationID...)
+	cp.Payload = append([]byte(nil), h.Payload...)
+	if h.Fallback != nil {
+		vn := *h.Fallback
+		vn.Id = append([]byte(nil), h.Fallback.Id...)
+		vn.Meta = append([]byte(nil), h.Fallback.Meta...)
+		cp.Fallback = &vn
+	}
+	return &cp
+}
+
+// HintStore is a local store of hints.  Hints are deep copied in and out so callers may
+// modify them.
+type HintStore interface {
+	Put(*Hint) error
+	Delete(id string) error
+	// Hints returns all hints ordered by creation time
+	Hints() ([]*Hint, error)
+}
+
+// InMemHintStore implements an in-memory HintStore
+type InMemHintStore struct {
+	mu    sync.RWMutex
+	hints map[string]*Hint
+}
+
+// NewInMemHintStore instantiates a new in-memory hint store
+func NewInMemHintStore() *InMemHintStore {
+	return &InMemHintStore{hints: make(map[string]*Hint)}
+}
+
+// Put adds or updates a copy of the hint
+func (hs *InMemHintStore) Put(hint *Hint) error {
+	cp := hint.clone()
+	hs.mu.Lock()
+	hs.hints[hint.ID] = cp
+	hs.mu.Unlock()
+	return nil
+}
+
+// Delete removes a hint
+func (hs *InMemHintStore) Delete(id string) error {
+	hs.mu.Lock()
+	delete(hs.hints, id)
+	hs.mu.Unlock()
+	return nil
+}
+
+// Hints returns a copy of all hints ordered by creation time
+func (hs *InMemHintStore) Hints() ([]*Hint, error) {
+	hs.mu.RLock()
+	out := make([]*Hint, 0, len(hs.hints))
+	for _, h := range hs.hints {
+		out = append(out, h.clone())
+	}
+	hs.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+// HintJSONStore implements a json file based HintStore.  It inherits the in-memory
+// store and writes the file on each change.
+type HintJSONStore struct {
+	filename string
+	perms    os.FileMode
+	// Serializes writes of the file
+	commitMu sync.Mutex
+	*InMemHintStore
+}
+
+// NewHintJSONStore loads the hints in the file if it exists
+func NewHintJSONStore(filename string) (*HintJSONStore, error) {
+	hs := &HintJSONStore{filename: filename, perms: 0600, InMemHintStore: NewInMemHintStore()}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hs, nil
+		}
+		return nil, err
+	}
+
+	var hints []*Hint
+	if err = json.Unmarshal(data, &hints); err != nil {
+		return nil, err
+	}
+	for _, h := range hints {
+		hs.hints[h.ID] = h
+	}
+	return hs, nil
+}
+
+// Put adds or updates a hint and writes the file
+func (hs *HintJSONStore) Put(hint *Hint) error {
+	hs.InMemHintStore.Put(hint)
+	return hs.Commit()
+}
+
+// Delete removes a hint and writes the file
+func (hs *HintJSONStore) Delete(id string) error {
+	hs.InMemHintStore.Delete(id)
+	return hs.Commit()
+}
+
+// Commit writes the hints to the file.  Commits are serialized so the file always holds
+// the latest snapshot.
+func (hs *HintJSONStore) Commit() error {
+	hs.commitMu.Lock()
+	defer hs.commitMu.Unlock()
+
+	hints, _ := hs.Hints()
+	b, err := json.MarshalIndent(hints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash does not leave a partial file
+	tmp := hs.filename + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, hs.perms); err != nil {
+		return err
+	}
+	return os.Rename(tmp, hs.filename)
+}
+
+// HandoffFunc transfers the hinted data to the target host.  It is supplied by the
+// storage layer.  A hint is deleted once it returns nil.
+type HandoffFunc func(ctx context.Context, hint *Hint) error
+
+// HintedHandoffConfig contains the hinted handoff options
+type HintedHandoffConfig struct {
+	// Store holding hints
+	Store HintStore
+	// Transfers a hint to its target
+	Handoff HandoffFunc
+	// Optional check that a host is reachable before replaying its hints
+	Reachable func(ctx context.Context, host string) bool
+	// How often hints are replayed.  Non-positive values default to 30s
+	ReplayInterval time.Duration
+	// Hints older than this are dropped.  Zero keeps hints until replayed
+	HintTTL time.Duration
+}
+
+// HintedHandoff picks fallback vnodes for unreachable replicas, records hints and
+// replays them once the target is reachable
+type HintedHandoff struct {
+	conf   *HintedHandoffConfig
+	lookup SuccessorLookup
+	logger Logger
+
+	// Serializes replays so a hint is not handed off twice
+	replayMu sync.Mutex
+
+	// Canceled by Shutdown to stop the replay loop and its in-flight handoffs
+	ctx          context.Context
+	cancel       context.CancelFunc
+	shutdownOnce sync.Once
+	wg           sync.WaitGroup
+}
+
+const defaultReplayInterval = 30 * time.Second
+
+// NewHintedHandoff instantiates a HintedHandoff using the ring to find fallback vnodes.
+// Call Start to replay hints in the background.
+func NewHintedHandoff(r *Ring, conf *HintedHandoffConfig) *HintedHandoff {
+	// Copied so the default is not written to the caller's config
+	c := *conf
+	if c.ReplayInterval <= 0 {
+		c.ReplayInterval = defaultReplayInterval
+	}
+
+	hh := &HintedHandoff{
+		conf:   &c,
+		lookup: r.successors,
+		logger: r.logger,
+	}
+	hh.ctx, hh.cancel = context.WithCancel(context.Background())
+	return hh
+}
+
+// Fallback returns the first successor of the location id whose host is not in the
+// location set
+func (hh *HintedHandoff) Fallback(ctx context.Context, locs LocationSet, loc *Location) (*chord.Vnode, error) {
+	vns, err := hh.lookup(ctx, loc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vn := range vns {
+		if _, err := locs.GetByHost(vn.Host); err != nil {
+			return vn, nil
+		}
+	}
+	return nil, fmt.Errorf("no fallback vnode for location: %x", loc.ID)
+}
+
+// Hint picks a fallback vnode for the unreachable location and records a hint for its
+// host.  The caller writes the payload to the returned fallback vnode.
+func (hh *HintedHandoff) Hint(ctx context.Context, locs LocationSet, loc *Location, payload []byte) (*Hint, error) {
+	fallback, err := hh.Fallback(ctx, locs, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	hint := &Hint{
+		ID:         hex.EncodeToString(id),
+		Target:     loc.Vnode.Host,
+		LocationID: loc.ID,
+		Fallback:   fallback,
+		Payload:    payload,
+		Created:    time.Now(),
+	}
+	return hint, hh.conf.Store.Put(hint)
+}
+
+// Replay hands off stored hints to their targets returning the number handed off.
+// Hints of unreachable targets are kept.  Expired hints are dropped.  It stops at the
+// first store error.  Concurrent replays are serialized.
+func (hh *HintedHandoff) Replay(ctx context.Context) (int, error) {
+	hh.replayMu.Lock()
+	defer hh.replayMu.Unlock()
+
+	hints, err := hh.conf.Store.Hints()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		done        int
+		unreachable = map[string]bool{}
+	)
+	for _, hint := range hints {
+		if err = ctx.Err(); err != nil {
+			return done, err
+		}
+
+		if hh.conf.HintTTL > 0 && time.Since(hint.Created) > hh.conf.HintTTL {
+			hh.logger.Warn("Dropping expired hint", "id", hint.ID, FieldPeer, hint.Target)
+			if err = hh.conf.Store.Delete(hint.ID); err != nil {
+				return done, err
+			}
+			continue
+		}
+
+		if unreachable[hint.Target] {
+			continue
+		}
+		if hh.conf.Reachable != nil && !hh.conf.Reachable(ctx, hint.Target) {
+			unreachable[hint.Target] = true
+			continue
+		}
+
+		if err = hh.conf.Handoff(ctx, hint); err != nil {
+			// Skip the rest of this target's hints until the next replay
+			unreachable[hint.Target] = true
+			hint.Attempts++
+			hh.logger.Warn("Hint handoff failed", "id", hint.ID, FieldPeer, hint.Target,
+				FieldAttempt, hint.Attempts, FieldError, errField(err))
+			if err = hh.conf.Store.Put(hint); err != nil {
+				return done, err
+			}
+			continue
+		}
+
+		if err = hh.conf.Store.Delete(hint.ID); err != nil {
+			return done, err
+		}
+		done++
+	}
+
+	return done, nil
+}
+
+// Start replays hints every ReplayInterval until Shutdown is called
+func (hh *HintedHandoff) Start() {
+	hh.wg.Add(1)
+	go func() {
+		defer hh.wg.Done()
+
+		ticker := time.NewTicker(hh.conf.ReplayInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-hh.ctx.Done():
+				return
+			}
+
+			if _, err := hh.Replay(hh.ctx); err != nil && hh.ctx.Err() == nil {
+				hh.logger.Error("Hint replay failed", FieldError, errField(err))
+			}
+		}
+	}()
+}
+
+// Shutdown stops replaying hints canceling a replay in progress.  It is safe to call
+// more than once.
+func (hh *HintedHandoff) Shutdown() {
+	hh.shutdownOnce.Do(hh.cancel)
+	hh.wg.Wait()
+}
@@ -0,0 +1,256 @@
+package hexaring
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func testHintedHandoff(t *testing.T, port string, conf *HintedHandoffConfig) *HintedHandoff {
+	r := New(fastConf("127.0.0.1:"+port), NewInMemPeerStore())
+	hh := NewHintedHandoff(r, conf)
+	hh.lookup = testRingLookup(testRingVnodes("h1", "h2", "h3", "h4"), 8)
+	return hh
+}
+
+func TestHintedHandoff_Hint(t *testing.T) {
+	store := NewInMemHintStore()
+	hh := testHintedHandoff(t, "47782", &HintedHandoffConfig{Store: store})
+
+	hash := sha1.Sum(testkey)
+	locs, err := NewEquidistantPlacement().Place(context.Background(), hh.lookup, hash[:], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hint, err := hh.Hint(context.Background(), locs, locs[1], []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint.Target != locs[1].Vnode.Host {
+		t.Fatal("wrong target")
+	}
+	if _, err = locs.GetByHost(hint.Fallback.Host); err == nil {
+		t.Fatal("fallback should not be in the location set")
+	}
+
+	hints, _ := store.Hints()
+	if len(hints) != 1 || string(hints[0].Payload) != "data" {
+		t.Fatal("hint should be stored")
+	}
+
+	// All hosts in the set
+	all, _ := NewEquidistantPlacement().Place(context.Background(), hh.lookup, hash[:], 4)
+	if _, err = hh.Fallback(context.Background(), all, all[0]); err == nil {
+		t.Fatal("should fail without a fallback")
+	}
+}
+
+func TestHintedHandoff_Replay(t *testing.T) {
+	store := NewInMemHintStore()
+	var handedOff []string
+	conf := &HintedHandoffConfig{
+		Store: store,
+		Handoff: func(ctx context.Context, hint *Hint) error {
+			if hint.Target == "down" {
+				return fmt.Errorf("unreachable")
+			}
+			handedOff = append(handedOff, hint.ID)
+			return nil
+		},
+		Reachable: func(ctx context.Context, host string) bool {
+			return host != "probe-down"
+		},
+		HintTTL: time.Hour,
+	}
+	hh := testHintedHandoff(t, "47783", conf)
+
+	now := time.Now()
+	store.Put(&Hint{ID: "1", Target: "up", Created: now})
+	store.Put(&Hint{ID: "2", Target: "down", Created: now.Add(time.Millisecond)})
+	store.Put(&Hint{ID: "3", Target: "down", Created: now.Add(2 * time.Millisecond)})
+	store.Put(&Hint{ID: "4", Target: "probe-down", Created: now})
+	store.Put(&Hint{ID: "5", Target: "up", Created: now.Add(-2 * time.Hour)})
+
+	n, err := hh.Replay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(handedOff) != 1 || handedOff[0] != "1" {
+		t.Fatal("should hand off one hint", n, handedOff)
+	}
+
+	hints, _ := store.Hints()
+	if len(hints) != 3 {
+		t.Fatal("should keep unreachable hints", len(hints))
+	}
+	for _, h := range hints {
+		if h.ID == "2" && h.Attempts != 1 {
+			t.Fatal("attempt should be counted")
+		}
+		if h.ID == "3" && h.Attempts != 0 {
+			t.Fatal("target should be skipped after a failure")
+		}
+	}
+}
+
+// failingHintStore fails writes
+type failingHintStore struct {
+	*InMemHintStore
+}
+
+func (fs failingHintStore) Put(*Hint) error        { return fmt.Errorf("put failed") }
+func (fs failingHintStore) Delete(id string) error { return fmt.Errorf("delete failed") }
+
+func TestHintedHandoff_ReplayStoreErrors(t *testing.T) {
+	mem := NewInMemHintStore()
+	conf := &HintedHandoffConfig{
+		Store: failingHintStore{mem},
+		Handoff: func(ctx context.Context, hint *Hint) error {
+			return fmt.Errorf("unreachable")
+		},
+		HintTTL: time.Hour,
+	}
+	hh := testHintedHandoff(t, "47785", conf)
+	if hh.conf.ReplayInterval != defaultReplayInterval || conf.ReplayInterval != 0 {
+		t.Fatal("should default the replay interval on a copy")
+	}
+
+	// Failed handoff
+	mem.Put(&Hint{ID: "1", Target: "down", Created: time.Now()})
+	if _, err := hh.Replay(context.Background()); err == nil || err.Error() != "put failed" {
+		t.Fatal("should return the put error", err)
+	}
+
+	// Expired
+	mem.Put(&Hint{ID: "1", Target: "down", Created: time.Now().Add(-2 * time.Hour)})
+	if _, err := hh.Replay(context.Background()); err == nil || err.Error() != "delete failed" {
+		t.Fatal("should return the delete error", err)
+	}
+}
+
+func TestHintedHandoff_ReplayConcurrent(t *testing.T) {
+	store := NewInMemHintStore()
+	var (
+		mu        sync.Mutex
+		handedOff = map[string]int{}
+	)
+	conf := &HintedHandoffConfig{
+		Store: store,
+		Handoff: func(ctx context.Context, hint *Hint) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			handedOff[hint.ID]++
+			mu.Unlock()
+			return nil
+		},
+	}
+	hh := testHintedHandoff(t, "47787", conf)
+	for i := 0; i < 10; i++ {
+		store.Put(&Hint{ID: fmt.Sprintf("%d", i), Created: time.Now()})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := hh.Replay(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(handedOff) != 10 {
+		t.Fatal("all hints should be handed off", len(handedOff))
+	}
+	for id, c := range handedOff {
+		if c != 1 {
+			t.Fatalf("hint %s handed off %d times", id, c)
+		}
+	}
+}
+
+func TestHintedHandoff_Shutdown(t *testing.T) {
+	store := NewInMemHintStore()
+	started := make(chan struct{}, 1)
+	conf := &HintedHandoffConfig{
+		Store: store,
+		// Hangs until canceled
+		Handoff: func(ctx context.Context, hint *Hint) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		ReplayInterval: time.Millisecond,
+	}
+	hh := testHintedHandoff(t, "47788", conf)
+	store.Put(&Hint{ID: "1", Created: time.Now()})
+
+	hh.Start()
+	<-started
+	hh.Shutdown()
+	hh.Shutdown()
+}
+
+func TestHintJSONStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hexaring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "hints.json")
+
+	hs, err := NewHintJSONStore(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs.Put(&Hint{ID: "1", Target: "h1", LocationID: []byte("loc"), Payload: []byte("a"), Created: time.Now()})
+	hs.Put(&Hint{ID: "2", Target: "h2", Created: time.Now()})
+	hs.Delete("2")
+
+	hs2, err := NewHintJSONStore(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hints, _ := hs2.Hints()
+	if len(hints) != 1 || hints[0].ID != "1" || string(hints[0].Payload) != "a" {
+		t.Fatal("hints should be persisted", hints)
+	}
+
+	// Returned hints are deep copies
+	hints[0].Attempts = 5
+	hints[0].Payload[0] = 'b'
+	if hints, _ = hs2.Hints(); hints[0].Attempts != 0 || string(hints[0].Payload) != "a" {
+		t.Fatal("stored hint should not be modified")
+	}
+
+	// Concurrent writes are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := hs2.Put(&Hint{ID: fmt.Sprintf("c%d", i), Created: time.Now()}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	hs3, err := NewHintJSONStore(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hints, _ = hs3.Hints(); len(hints) != 21 {
+		t.Fatal("concurrent hints should be persisted", len(hints))
+	}
+}
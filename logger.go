@@ -0,0 +1,89 @@
+package hexaring
+
+import (
+	"log/slog"
+)
+
+// Log field keys
+const (
+	FieldPeer    = "peer"
+	FieldHash    = "hash"
+	FieldAttempt = "attempt"
+	FieldError   = "error"
+)
+
+// Logger is a leveled structured logger.  Fields are alternating keys and values e.g.
+// Warn("Join failed", FieldPeer, peer, FieldError, err).
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// NopLogger discards all logs
+type NopLogger struct{}
+
+// Debug satisfies the Logger interface
+func (NopLogger) Debug(msg string, fields ...interface{}) {}
+
+// Info satisfies the Logger interface
+func (NopLogger) Info(msg string, fields ...interface{}) {}
+
+// Warn satisfies the Logger interface
+func (NopLogger) Warn(msg string, fields ...interface{}) {}
+
+// Error satisfies the Logger interface
+func (NopLogger) Error(msg string, fields ...interface{}) {}
+
+// SlogLogger is a Logger writing to a log/slog logger
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to the slog logger.  A nil logger writes to the
+// slog default logger at the time of each call.  Use a slog.JSONHandler for JSON output.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+func (sl *SlogLogger) get() *slog.Logger {
+	if sl.logger == nil {
+		return slog.Default()
+	}
+	return sl.logger
+}
+
+// Debug satisfies the Logger interface
+func (sl *SlogLogger) Debug(msg string, fields ...interface{}) {
+	sl.get().Debug(msg, fields...)
+}
+
+// Info satisfies the Logger interface
+func (sl *SlogLogger) Info(msg string, fields ...interface{}) {
+	sl.get().Info(msg, fields...)
+}
+
+// Warn satisfies the Logger interface
+func (sl *SlogLogger) Warn(msg string, fields ...interface{}) {
+	sl.get().Warn(msg, fields...)
+}
+
+// Error satisfies the Logger interface
+func (sl *SlogLogger) Error(msg string, fields ...interface{}) {
+	sl.get().Error(msg, fields...)
+}
+
+// defaultLogger returns the logger used when none is configured
+func defaultLogger() Logger {
+	return NewSlogLogger(nil)
+}
+
+// errField returns the error as a log field value.  slog would otherwise encode some
+// errors as an empty JSON object.
+func errField(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
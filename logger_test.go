@@ -0,0 +1,127 @@
+package hexaring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// testJSONLogger returns a logger writing JSON lines to the buffer at all levels
+func testJSONLogger(buf *bytes.Buffer) Logger {
+	return NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+// testLogs decodes the JSON lines in the buffer
+func testLogs(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := testJSONLogger(&buf)
+
+	logger.Debug("debug")
+	logger.Info("info", FieldPeer, "127.0.0.1:1")
+	logger.Warn("warn", FieldAttempt, 2)
+	logger.Error("error", FieldError, errField(fmt.Errorf("failed")))
+
+	logs := testLogs(t, &buf)
+	if len(logs) != 4 {
+		t.Fatal("should have 4 logs", len(logs))
+	}
+	for i, level := range []string{"DEBUG", "INFO", "WARN", "ERROR"} {
+		if logs[i]["level"] != level {
+			t.Fatalf("wrong level want=%s got=%v", level, logs[i]["level"])
+		}
+	}
+	if logs[1][FieldPeer] != "127.0.0.1:1" || logs[2][FieldAttempt] != float64(2) || logs[3][FieldError] != "failed" {
+		t.Fatal("fields not logged", logs)
+	}
+
+	// Nil uses the default logger and nop discards
+	NewSlogLogger(nil).Debug("debug")
+	NopLogger{}.Error("error")
+}
+
+func TestEquidistantPlacement_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewEquidistantPlacement()
+	p.SetLogger(testJSONLogger(&buf))
+
+	hash := []byte{0x01, 0x02}
+	lookup := func(ctx context.Context, h []byte) ([]*chord.Vnode, error) {
+		return nil, fmt.Errorf("unreachable")
+	}
+	if _, err := p.Place(context.Background(), lookup, hash, 1); err == nil {
+		t.Fatal("should fail")
+	}
+
+	logs := testLogs(t, &buf)
+	if len(logs) != 1 || logs[0][FieldHash] != hex.EncodeToString(hash) || logs[0][FieldError] != "unreachable" {
+		t.Fatal("lookup failure not logged", logs)
+	}
+}
+
+func TestPeerJSONStore_Logger(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hexaring")
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	ps, _ := NewPeerJSONStore(filepath.Join(dir, "missing", "peers.json"))
+	ps.SetLogger(testJSONLogger(&buf))
+
+	if !ps.AddPeer("127.0.0.1:1") {
+		t.Fatal("peer should be added")
+	}
+
+	logs := testLogs(t, &buf)
+	if len(logs) != 2 {
+		t.Fatal("should log the add and the write failure", logs)
+	}
+	if logs[1]["level"] != "ERROR" || logs[1][FieldPeer] != "127.0.0.1:1" || logs[1][FieldError] == "" {
+		t.Fatal("write failure not logged", logs[1])
+	}
+}
+
+func TestRing_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	conf := fastConf("127.0.0.1:17245")
+	conf.Logger = testJSONLogger(&buf)
+	conf.JoinBackoff = &ExponentialBackoff{Initial: time.Millisecond, Multiplier: 1, MaxAttempts: 2}
+
+	r := New(conf, NewInMemPeerStore())
+	if err := r.RetryJoin(); err == nil {
+		t.Fatal("should fail with no peers")
+	}
+
+	logs := testLogs(t, &buf)
+	if len(logs) != 3 {
+		t.Fatal("should have 3 logs", logs)
+	}
+	if logs[0][FieldAttempt] != float64(1) || logs[2][FieldAttempt] != float64(2) || logs[2][FieldError] == nil {
+		t.Fatal("join failures not logged", logs)
+	}
+	if logs[1]["msg"] != "Retrying join" || logs[1]["wait"] == nil {
+		t.Fatal("retry not logged", logs[1])
+	}
+}
@@ -0,0 +1,318 @@
+package hexaring
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxMerkleDepth is the max depth of a merkle tree
+const MaxMerkleDepth = 20
+
+// MerkleTree is a hash tree over the key hashes of an arc.  The arc is split into
+// 2^depth equal buckets, one per leaf.  A leaf is the xor of the hashes of its entries
+// so entries can be added and removed in any order.  Trees of replicas with the same
+// entries for an arc have the same root.
+type MerkleTree struct {
+	start []byte
+	end   []byte
+	depth int
+
+	// Arc length and circumference
+	length *big.Int
+	circum *big.Int
+
+	mu     sync.Mutex
+	leaves [][]byte
+	// Built levels.  nil when entries changed since the last build
+	levels [][][]byte
+}
+
+// NewMerkleTree returns an empty tree over the arc from start exclusive to end
+// inclusive.  Equal start and end cover the whole ring.
+func NewMerkleTree(start, end []byte, depth int) (*MerkleTree, error) {
+	if len(start) == 0 || len(start) != len(end) {
+		return nil, fmt.Errorf("invalid arc")
+	}
+	if depth < 0 || depth > MaxMerkleDepth {
+		return nil, fmt.Errorf("depth must be between 0 and %d: %d", MaxMerkleDepth, depth)
+	}
+
+	t := &MerkleTree{
+		start:  start,
+		end:    end,
+		depth:  depth,
+		circum: new(big.Int).Lsh(big.NewInt(1), uint(len(start)*8)),
+		leaves: make([][]byte, 1<<uint(depth)),
+	}
+	t.length = t.offset(end)
+	if t.length.Sign() == 0 {
+		t.length.Set(t.circum)
+	}
+
+	for i := range t.leaves {
+		t.leaves[i] = make([]byte, sha1.Size)
+	}
+	return t, nil
+}
+
+// offset returns the clockwise distance from the start to the hash
+func (t *MerkleTree) offset(hash []byte) *big.Int {
+	o := new(big.Int).SetBytes(hash)
+	o.Sub(o, new(big.Int).SetBytes(t.start))
+	return o.Mod(o, t.circum)
+}
+
+// bucket returns the leaf index of a key hash or -1 if it is not in the arc
+func (t *MerkleTree) bucket(hash []byte) int {
+	if len(hash) != len(t.start) {
+		return -1
+	}
+	o := t.offset(hash)
+	if o.Sign() == 0 {
+		// The start is excluded unless the arc is the whole ring
+		if t.length.Cmp(t.circum) != 0 {
+			return -1
+		}
+		o.Set(t.circum)
+	}
+	if o.Cmp(t.length) > 0 {
+		return -1
+	}
+
+	// The bucket i with bound(i) < o <= bound(i+1) where bound(i) = i*length/2^depth
+	o.Lsh(o, uint(t.depth))
+	o.Sub(o, big.NewInt(1))
+	return int(o.Div(o, t.length).Int64())
+}
+
+// Add adds an entry.  The digest identifies the entry content e.g. a version or a hash
+// of the value, and may be nil.  Adding an existing entry again removes it.
+func (t *MerkleTree) Add(keyHash, digest []byte) error {
+	i := t.bucket(keyHash)
+	if i < 0 {
+		return fmt.Errorf("hash not in arc: %x", keyHash)
+	}
+
+	h := sha1.New()
+	h.Write(keyHash)
+	h.Write(digest)
+	sum := h.Sum(nil)
+
+	t.mu.Lock()
+	for j := range sum {
+		t.leaves[i][j] ^= sum[j]
+	}
+	t.levels = nil
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Remove removes an entry previously added with the same digest
+func (t *MerkleTree) Remove(keyHash, digest []byte) error {
+	return t.Add(keyHash, digest)
+}
+
+// build computes all levels from the leaves.  It must be called with the lock held.
+func (t *MerkleTree) build() {
+	if t.levels != nil {
+		return
+	}
+
+	levels := make([][][]byte, t.depth+1)
+	levels[t.depth] = make([][]byte, len(t.leaves))
+	for i, l := range t.leaves {
+		levels[t.depth][i] = append([]byte(nil), l...)
+	}
+
+	for d := t.depth - 1; d >= 0; d-- {
+		below := levels[d+1]
+		levels[d] = make([][]byte, len(below)/2)
+		for i := range levels[d] {
+			h := sha1.New()
+			h.Write(below[2*i])
+			h.Write(below[2*i+1])
+			levels[d][i] = h.Sum(nil)
+		}
+	}
+	t.levels = levels
+}
+
+// Root returns the root hash
+func (t *MerkleTree) Root() []byte {
+	hashes, _ := t.Level(0, []int{0})
+	return hashes[0]
+}
+
+// Depth returns the depth of the tree
+func (t *MerkleTree) Depth() int {
+	return t.depth
+}
+
+// Level returns copies of the hashes of the nodes at the given indexes of a level.
+// Level zero is the root and level Depth the leaves.
+func (t *MerkleTree) Level(level int, indexes []int) ([][]byte, error) {
+	if level < 0 || level > t.depth {
+		return nil, fmt.Errorf("invalid level: %d", level)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.build()
+	nodes := t.levels[level]
+	out := make([][]byte, len(indexes))
+	for i, idx := range indexes {
+		if idx < 0 || idx >= len(nodes) {
+			return nil, fmt.Errorf("invalid index at level %d: %d", level, idx)
+		}
+		// Copied so callers cannot modify the built tree
+		out[i] = append([]byte(nil), nodes[idx]...)
+	}
+	return out, nil
+}
+
+// LeafArc returns the arc covered by a leaf
+func (t *MerkleTree) LeafArc(i int) *Arc {
+	return &Arc{Start: t.bound(i), End: t.bound(i + 1)}
+}
+
+// bound returns the hash at the start of bucket i
+func (t *MerkleTree) bound(i int) []byte {
+	o := new(big.Int).Mul(t.length, big.NewInt(int64(i)))
+	o.Rsh(o, uint(t.depth))
+	o.Add(o, new(big.Int).SetBytes(t.start))
+	o.Mod(o, t.circum)
+
+	b := o.Bytes()
+	if len(b) < len(t.start) {
+		b = append(make([]byte, len(t.start)-len(b)), b...)
+	}
+	return b
+}
+
+// MerkleLevelFunc returns the hashes of the nodes at the given indexes of a level of a
+// remote tree
+type MerkleLevelFunc func(ctx context.Context, level int, indexes []int) ([][]byte, error)
+
+// MerkleDiff compares the local tree with a remote one over the same arc and depth
+// returning the arcs of the differing leaves.  Only the children of differing nodes are
+// requested from the remote.  Adjacent arcs are merged.
+func MerkleDiff(ctx context.Context, local *MerkleTree, remote MerkleLevelFunc) ([]*Arc, error) {
+	indexes := []int{0}
+
+	for level := 0; ; level++ {
+		theirs, err := remote(ctx, level, indexes)
+		if err != nil {
+			return nil, err
+		}
+		ours, err := local.Level(level, indexes)
+		if err != nil {
+			return nil, err
+		}
+		if len(theirs) != len(ours) {
+			return nil, fmt.Errorf("remote returned %d hashes for %d nodes", len(theirs), len(ours))
+		}
+
+		var differ []int
+		for i := range ours {
+			if !bytes.Equal(ours[i], theirs[i]) {
+				differ = append(differ, indexes[i])
+			}
+		}
+		if len(differ) == 0 {
+			return nil, nil
+		}
+
+		if level == local.depth {
+			return local.leafArcs(differ), nil
+		}
+
+		indexes = make([]int, 0, 2*len(differ))
+		for _, i := range differ {
+			indexes = append(indexes, 2*i, 2*i+1)
+		}
+	}
+}
+
+// leafArcs returns the arcs of the ordered leaf indexes merging adjacent ones
+func (t *MerkleTree) leafArcs(leaves []int) []*Arc {
+	var out []*Arc
+	for i := 0; i < len(leaves); {
+		j := i
+		for j+1 < len(leaves) && leaves[j+1] == leaves[j]+1 {
+			j++
+		}
+		out = append(out, &Arc{Start: t.bound(leaves[i]), End: t.bound(leaves[j] + 1)})
+		i = j + 1
+	}
+	return out
+}
+
+// MerkleTreeProvider returns the tree over an arc at the given depth.  It is supplied by
+// the application serving the MerkleRPC.
+type MerkleTreeProvider func(start, end []byte, depth int) (*MerkleTree, error)
+
+// MerkleService serves merkle tree levels to peer replicas
+type MerkleService struct {
+	provider MerkleTreeProvider
+}
+
+// NewMerkleService instantiates a MerkleService serving trees from the provider
+func NewMerkleService(provider MerkleTreeProvider) *MerkleService {
+	return &MerkleService{provider: provider}
+}
+
+// RegisterServer registers the service to the grpc server
+func (ms *MerkleService) RegisterServer(server *grpc.Server) {
+	RegisterMerkleRPCServer(server, ms)
+}
+
+// MerkleLevelRPC serves a MerkleLevel request.  A request may not ask for more indexes
+// than there are nodes in the level.  NotFound is returned if the provider has no tree
+// and FailedPrecondition if its tree is over another arc or depth.
+func (ms *MerkleService) MerkleLevelRPC(ctx context.Context, req *MerkleLevelRequest) (*MerkleLevelResponse, error) {
+	if req.Depth < 0 || req.Depth > MaxMerkleDepth {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid depth: %d", req.Depth)
+	}
+	if req.Level < 0 || req.Level > req.Depth {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid level: %d", req.Level)
+	}
+	if len(req.Indexes) > 1<<uint(req.Level) {
+		return nil, status.Errorf(codes.InvalidArgument, "too many indexes for level %d: %d",
+			req.Level, len(req.Indexes))
+	}
+
+	tree, err := ms.provider(req.Start, req.End, int(req.Depth))
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return nil, status.Errorf(codes.NotFound, "no merkle tree for arc")
+	}
+	if !bytes.Equal(tree.start, req.Start) || !bytes.Equal(tree.end, req.End) {
+		return nil, status.Errorf(codes.FailedPrecondition, "tree arc mismatch: %x-%x != %x-%x",
+			tree.start, tree.end, req.Start, req.End)
+	}
+	if tree.depth != int(req.Depth) {
+		return nil, status.Errorf(codes.FailedPrecondition, "tree depth mismatch: %d != %d",
+			tree.depth, req.Depth)
+	}
+
+	indexes := make([]int, len(req.Indexes))
+	for i, idx := range req.Indexes {
+		indexes[i] = int(idx)
+	}
+
+	resp := &MerkleLevelResponse{}
+	resp.Hashes, err = tree.Level(int(req.Level), indexes)
+	return resp, err
+}
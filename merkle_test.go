@@ -0,0 +1,217 @@
+package hexaring
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testMerkleHash(b0 byte, rest ...byte) []byte {
+	h := make([]byte, sha1.Size)
+	h[0] = b0
+	copy(h[1:], rest)
+	return h
+}
+
+func TestMerkleTree_Buckets(t *testing.T) {
+	var (
+		start = testMerkleHash(0x10)
+		end   = testMerkleHash(0x20)
+	)
+	tree, err := NewMerkleTree(start, end, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.bucket(start) != -1 {
+		t.Fatal("start should be excluded")
+	}
+	if tree.bucket(end) != 15 {
+		t.Fatal("end should be in the last bucket", tree.bucket(end))
+	}
+	if tree.bucket(testMerkleHash(0x30)) != -1 {
+		t.Fatal("hash outside arc should be excluded")
+	}
+
+	// Each bucket's arc contains the hashes mapped to it
+	for i := 0; i < 16; i++ {
+		arc := tree.LeafArc(i)
+		if tree.bucket(arc.End) != i {
+			t.Fatalf("leaf %d end maps to %d", i, tree.bucket(arc.End))
+		}
+		if i > 0 && tree.bucket(arc.Start) != i-1 {
+			t.Fatalf("leaf %d start maps to %d", i, tree.bucket(arc.Start))
+		}
+	}
+	if !bytes.Equal(tree.LeafArc(15).End, end) {
+		t.Fatal("last leaf should end at the arc end")
+	}
+
+	// Wrapping arc
+	wrap, _ := NewMerkleTree(testMerkleHash(0xf0), testMerkleHash(0x10), 2)
+	if wrap.bucket(testMerkleHash(0x00)) < 0 || wrap.bucket(testMerkleHash(0x80)) >= 0 {
+		t.Fatal("wrapping arc buckets wrong")
+	}
+
+	// Whole ring
+	ring, _ := NewMerkleTree(start, start, 3)
+	if ring.bucket(start) != 7 || ring.bucket(testMerkleHash(0x80)) < 0 {
+		t.Fatal("whole ring buckets wrong")
+	}
+
+	if _, err = NewMerkleTree(start, end, MaxMerkleDepth+1); err == nil {
+		t.Fatal("should fail with large depth")
+	}
+	if err = tree.Add(testMerkleHash(0x30), nil); err == nil {
+		t.Fatal("should fail to add hash outside arc")
+	}
+}
+
+func testMerkleTrees(t *testing.T, n int) (*MerkleTree, *MerkleTree) {
+	start, end := testMerkleHash(0x00), testMerkleHash(0x80)
+	a, _ := NewMerkleTree(start, end, 6)
+	b, _ := NewMerkleTree(start, end, 6)
+
+	for i := 0; i < n; i++ {
+		k := sha1.Sum([]byte(fmt.Sprintf("key-%d", i)))
+		k[0] &= 0x7f
+		if k == [sha1.Size]byte{} {
+			continue
+		}
+		a.Add(k[:], []byte("v1"))
+		b.Add(k[:], []byte("v1"))
+	}
+	return a, b
+}
+
+func TestMerkleDiff(t *testing.T) {
+	a, b := testMerkleTrees(t, 500)
+	if !bytes.Equal(a.Root(), b.Root()) {
+		t.Fatal("roots should match")
+	}
+
+	var requested int
+	remote := func(ctx context.Context, level int, indexes []int) ([][]byte, error) {
+		requested += len(indexes)
+		return b.Level(level, indexes)
+	}
+
+	diff, err := MerkleDiff(context.Background(), a, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 || requested != 1 {
+		t.Fatal("equal trees should only compare roots", diff, requested)
+	}
+
+	// Change a value and add a key on one side
+	k1 := testMerkleHash(0x11, 1)
+	k2 := testMerkleHash(0x12, 2)
+	a.Add(k1, []byte("v1"))
+	b.Add(k1, []byte("v2"))
+	b.Add(k2, nil)
+
+	requested = 0
+	diff, err = MerkleDiff(context.Background(), a, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) == 0 || requested > 2*6+1 {
+		t.Fatal("should find a small diff", diff, requested)
+	}
+
+	contains := func(h []byte) bool {
+		for _, arc := range diff {
+			if (&OwnershipEvent{Start: arc.Start, End: arc.End}).Contains(h) {
+				return true
+			}
+		}
+		return false
+	}
+	if !contains(k1) || !contains(k2) {
+		t.Fatal("diff should contain the changed keys")
+	}
+
+	// Removing restores equality
+	a.Remove(k1, []byte("v1"))
+	a.Add(k1, []byte("v2"))
+	a.Add(k2, nil)
+	if !bytes.Equal(a.Root(), b.Root()) {
+		t.Fatal("roots should match after repair")
+	}
+}
+
+func TestMerkleService(t *testing.T) {
+	a, b := testMerkleTrees(t, 200)
+	b.Add(testMerkleHash(0x40, 4), nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:16945")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	NewMerkleService(func(start, end []byte, depth int) (*MerkleTree, error) {
+		if !bytes.Equal(start, b.start) || !bytes.Equal(end, b.end) {
+			return nil, fmt.Errorf("unknown arc")
+		}
+		return b, nil
+	}).RegisterServer(server)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	client := NewNetClient(2*time.Second, 10*time.Second)
+	defer client.Shutdown()
+
+	diff, err := MerkleDiff(context.Background(), a, client.MerkleLevels("127.0.0.1:16945", a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 {
+		t.Fatal("should have one differing arc", diff)
+	}
+
+	other, _ := NewMerkleTree(testMerkleHash(0x01), testMerkleHash(0x02), 6)
+	if _, err = MerkleDiff(context.Background(), other, client.MerkleLevels("127.0.0.1:16945", other)); err == nil {
+		t.Fatal("should fail for unknown arc")
+	}
+}
+
+func TestMerkleService_InvalidRequests(t *testing.T) {
+	tree, _ := NewMerkleTree(testMerkleHash(0x00), testMerkleHash(0x80), 2)
+	ms := NewMerkleService(func(start, end []byte, depth int) (*MerkleTree, error) {
+		if start[0] != 0x00 {
+			return nil, nil
+		}
+		return tree, nil
+	})
+
+	cases := map[*MerkleLevelRequest]codes.Code{
+		{Start: tree.start, End: tree.end, Depth: 2, Level: 1, Indexes: []int32{0, 1}}:          codes.OK,
+		{Start: tree.start, End: tree.end, Depth: 2, Level: 1, Indexes: []int32{0, 1, 0}}:       codes.InvalidArgument,
+		{Start: tree.start, End: tree.end, Depth: 2, Level: 3}:                                  codes.InvalidArgument,
+		{Start: tree.start, End: tree.end, Depth: MaxMerkleDepth + 1}:                           codes.InvalidArgument,
+		{Start: testMerkleHash(0x01), End: tree.end, Depth: 2, Level: 0, Indexes: []int32{0}}:   codes.NotFound,
+		{Start: tree.start, End: testMerkleHash(0x40), Depth: 2, Level: 0, Indexes: []int32{0}}: codes.FailedPrecondition,
+		{Start: tree.start, End: tree.end, Depth: 3, Level: 0, Indexes: []int32{0}}:             codes.FailedPrecondition,
+	}
+	for req, want := range cases {
+		_, err := ms.MerkleLevelRPC(context.Background(), req)
+		if got := status.Code(err); got != want {
+			t.Errorf("%+v: want %v got %v", req, want, got)
+		}
+	}
+	// Returned hashes are copies
+	hashes, _ := tree.Level(0, []int{0})
+	hashes[0][0] ^= 0xff
+	if bytes.Equal(tree.Root(), hashes[0]) {
+		t.Fatal("tree should not be modified")
+	}
+}
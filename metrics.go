@@ -0,0 +1,358 @@
+package hexaring
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metric names
+const (
+	// Histogram of ring replicated lookup durations by method, outcome and replicas
+	MetricLookupDuration = "hexaring_lookup_duration_seconds"
+	// Histogram of hosts visited per scour by method and outcome
+	MetricScourVisited = "hexaring_scour_visited_hosts"
+	// Counter of join attempts by outcome
+	MetricJoinAttempts = "hexaring_join_attempts_total"
+	// Counter of join retries after all peers failed
+	MetricJoinRetries = "hexaring_join_retries_total"
+	// Histogram of NetClient rpc durations by method, outcome and replicas
+	MetricClientRPCDuration = "hexaring_client_rpc_duration_seconds"
+	// Histogram of NetTransport rpc durations by method, outcome and replicas
+	MetricServerRPCDuration = "hexaring_server_rpc_duration_seconds"
+)
+
+// Metric label names
+const (
+	LabelMethod   = "method"
+	LabelOutcome  = "outcome"
+	LabelReplicas = "replicas"
+)
+
+// Metrics receives measurements of ring operations
+type Metrics interface {
+	// IncrCounter adds the delta to the counter with the labels
+	IncrCounter(name string, labels map[string]string, delta float64)
+	// Observe records the value in the histogram with the labels
+	Observe(name string, labels map[string]string, value float64)
+}
+
+// NopMetrics discards all measurements
+type NopMetrics struct{}
+
+// IncrCounter satisfies the Metrics interface
+func (NopMetrics) IncrCounter(name string, labels map[string]string, delta float64) {}
+
+// Observe satisfies the Metrics interface
+func (NopMetrics) Observe(name string, labels map[string]string, value float64) {}
+
+var (
+	// DefaultDurationBuckets are the histogram buckets in seconds used for durations
+	DefaultDurationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultCountBuckets are the histogram buckets used for counts
+	DefaultCountBuckets = []float64{1, 2, 4, 8, 16, 32, 64, 128, 256}
+)
+
+var metricHelp = map[string]string{
+	MetricLookupDuration:    "Replicated lookup duration in seconds.",
+	MetricScourVisited:      "Hosts visited per scour.",
+	MetricJoinAttempts:      "Ring join attempts.",
+	MetricJoinRetries:       "Ring join retries after all peers failed.",
+	MetricClientRPCDuration: "Client rpc duration in seconds.",
+	MetricServerRPCDuration: "Server rpc duration in seconds.",
+}
+
+// metricOutcome returns the outcome label of an error
+func metricOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case err == context.Canceled:
+		return "canceled"
+	case err == context.DeadlineExceeded:
+		return "deadline_exceeded"
+	case strings.Contains(err.Error(), errNotEnoughHosts.Error()):
+		// Also matches the error relayed by a remote host
+		return "not_enough_hosts"
+	}
+
+	if st, ok := status.FromError(err); ok {
+		return toSnakeCase(st.Code().String())
+	}
+	return "error"
+}
+
+// toSnakeCase converts a CamelCase name to snake case
+func toSnakeCase(s string) string {
+	var b bytes.Buffer
+	for i, c := range s {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// invalidReplicas is the replica count of a rpc whose requested count was rejected
+const invalidReplicas = -1
+
+// rpcLabels returns the labels of a rpc.  The replicas label is only set if n > 0, or to
+// "invalid" for invalidReplicas.
+func rpcLabels(method string, n int, err error) map[string]string {
+	labels := map[string]string{LabelMethod: method, LabelOutcome: metricOutcome(err)}
+	if n > 0 {
+		labels[LabelReplicas] = strconv.Itoa(n)
+	} else if n == invalidReplicas {
+		labels[LabelReplicas] = "invalid"
+	}
+	return labels
+}
+
+// unaryMetricsInterceptor records the duration of each outgoing unary call
+func unaryMetricsInterceptor(m Metrics) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.Observe(MetricClientRPCDuration, rpcLabels(rpcMethodName(method), requestReplicas(method, req), err), time.Since(start).Seconds())
+		return err
+	}
+}
+
+// streamMetricsInterceptor records the time taken to open each outgoing stream
+func streamMetricsInterceptor(m Metrics) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		m.Observe(MetricClientRPCDuration, rpcLabels(rpcMethodName(method), 0, err), time.Since(start).Seconds())
+		return stream, err
+	}
+}
+
+// rpcMethodName returns the method name of a full grpc method
+func rpcMethodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// requestReplicas returns the replica count of a replicated lookup request or 0
+func requestReplicas(method string, req interface{}) int {
+	if !strings.Contains(method, "Replicated") {
+		return 0
+	}
+	switch r := req.(type) {
+	case *LookupRequest:
+		return int(r.N)
+	case *LookupBatchRequest:
+		return int(r.N)
+	}
+	return 0
+}
+
+type promSeries struct {
+	labels string
+	value  float64
+	// Histogram only
+	counts []uint64
+	count  uint64
+}
+
+type promFamily struct {
+	name      string
+	histogram bool
+	buckets   []float64
+	series    map[string]*promSeries
+}
+
+// PrometheusMetrics implements Metrics keeping counters and histograms in memory.  It
+// is a http.Handler serving them in the Prometheus text exposition format.
+type PrometheusMetrics struct {
+	mu       sync.Mutex
+	buckets  map[string][]float64
+	families map[string]*promFamily
+}
+
+// NewPrometheusMetrics instantiates a PrometheusMetrics.  Histograms use the
+// DefaultCountBuckets for the scour metric and the DefaultDurationBuckets for all
+// others unless set with SetBuckets.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		buckets:  map[string][]float64{MetricScourVisited: DefaultCountBuckets},
+		families: make(map[string]*promFamily),
+	}
+}
+
+// SetBuckets sets the upper bounds of the buckets of a histogram.  It must be called
+// before the first observation.
+func (pm *PrometheusMetrics) SetBuckets(name string, buckets []float64) {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	pm.mu.Lock()
+	pm.buckets[name] = b
+	pm.mu.Unlock()
+}
+
+// IncrCounter satisfies the Metrics interface
+func (pm *PrometheusMetrics) IncrCounter(name string, labels map[string]string, delta float64) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if s := pm.series(name, false, labels); s != nil {
+		s.value += delta
+	}
+}
+
+// Observe satisfies the Metrics interface
+func (pm *PrometheusMetrics) Observe(name string, labels map[string]string, value float64) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	s := pm.series(name, true, labels)
+	if s == nil {
+		return
+	}
+	for i, ub := range pm.families[name].buckets {
+		if value <= ub {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// series returns the series creating it if needed.  nil is returned if the name is
+// already used by a metric of the other type.  It must be called with the lock held.
+func (pm *PrometheusMetrics) series(name string, histogram bool, labels map[string]string) *promSeries {
+	fam, ok := pm.families[name]
+	if ok && fam.histogram != histogram {
+		return nil
+	}
+	if !ok {
+		fam = &promFamily{name: name, histogram: histogram, series: make(map[string]*promSeries)}
+		if histogram {
+			if fam.buckets = pm.buckets[name]; fam.buckets == nil {
+				fam.buckets = DefaultDurationBuckets
+			}
+		}
+		pm.families[name] = fam
+	}
+
+	key := formatLabels(labels)
+	s, ok := fam.series[key]
+	if !ok {
+		s = &promSeries{labels: key}
+		if fam.histogram {
+			s.counts = make([]uint64, len(fam.buckets))
+		}
+		fam.series[key] = s
+	}
+	return s
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format
+func (pm *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(pm.Bytes())
+}
+
+// Bytes returns all metrics in the Prometheus text format ordered by name and labels
+func (pm *PrometheusMetrics) Bytes() []byte {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	names := make([]string, 0, len(pm.families))
+	for name := range pm.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fam := pm.families[name]
+		if help, ok := metricHelp[name]; ok {
+			fmt.Fprintf(&buf, "# HELP %s %s\n", name, help)
+		}
+		if fam.histogram {
+			fmt.Fprintf(&buf, "# TYPE %s histogram\n", name)
+		} else {
+			fmt.Fprintf(&buf, "# TYPE %s counter\n", name)
+		}
+
+		keys := make([]string, 0, len(fam.series))
+		for k := range fam.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			s := fam.series[k]
+			if !fam.histogram {
+				fmt.Fprintf(&buf, "%s%s %s\n", name, wrapLabels(s.labels), formatFloat(s.value))
+				continue
+			}
+
+			for i, ub := range fam.buckets {
+				fmt.Fprintf(&buf, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(s.labels, `le="`+formatFloat(ub)+`"`)), s.counts[i])
+			}
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(s.labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", name, wrapLabels(s.labels), formatFloat(s.value))
+			fmt.Fprintf(&buf, "%s_count%s %d\n", name, wrapLabels(s.labels), s.count)
+		}
+	}
+	return buf.Bytes()
+}
+
+// formatLabels returns the labels ordered by name in the exposition format without
+// braces
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + `="` + labelEscaper.Replace(labels[n]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,165 @@
+package hexaring
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func TestMetricOutcome(t *testing.T) {
+	cases := map[string]error{
+		"ok":                nil,
+		"canceled":          context.Canceled,
+		"deadline_exceeded": context.DeadlineExceeded,
+		"not_enough_hosts":  status.Errorf(codes.Unknown, "%v", errNotEnoughHosts),
+		"permission_denied": status.Errorf(codes.PermissionDenied, "denied"),
+		"error":             fmt.Errorf("other"),
+	}
+	for want, err := range cases {
+		if got := metricOutcome(err); got != want {
+			t.Errorf("%v: want %s got %s", err, want, got)
+		}
+	}
+}
+
+func TestRPCLabels(t *testing.T) {
+	if _, ok := rpcLabels("LookupRPC", 0, nil)[LabelReplicas]; ok {
+		t.Fatal("replicas should not be set")
+	}
+	if l := rpcLabels("LookupReplicatedRPC", 3, nil); l[LabelReplicas] != "3" {
+		t.Fatal("wrong replicas", l)
+	}
+	if l := rpcLabels("LookupReplicatedRPC", invalidReplicas, nil); l[LabelReplicas] != "invalid" {
+		t.Fatal("wrong replicas", l)
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	pm := NewPrometheusMetrics()
+	pm.SetBuckets("test_seconds", []float64{1, 0.5})
+
+	pm.IncrCounter(MetricJoinAttempts, map[string]string{LabelOutcome: "ok"}, 1)
+	pm.IncrCounter(MetricJoinAttempts, map[string]string{LabelOutcome: "ok"}, 2)
+	pm.IncrCounter(MetricJoinRetries, nil, 1)
+	pm.Observe("test_seconds", map[string]string{"b": "2", "a": `x"y`}, 0.25)
+	pm.Observe("test_seconds", map[string]string{"a": `x"y`, "b": "2"}, 0.75)
+	// Type mismatch is ignored
+	pm.Observe(MetricJoinRetries, nil, 1)
+
+	srv := httptest.NewServer(pm)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatal("wrong content type", resp.Header.Get("Content-Type"))
+	}
+
+	want := `# HELP hexaring_join_attempts_total Ring join attempts.
+# TYPE hexaring_join_attempts_total counter
+hexaring_join_attempts_total{outcome="ok"} 3
+# HELP hexaring_join_retries_total Ring join retries after all peers failed.
+# TYPE hexaring_join_retries_total counter
+hexaring_join_retries_total 1
+# TYPE test_seconds histogram
+test_seconds_bucket{a="x\"y",b="2",le="0.5"} 1
+test_seconds_bucket{a="x\"y",b="2",le="1"} 2
+test_seconds_bucket{a="x\"y",b="2",le="+Inf"} 2
+test_seconds_sum{a="x\"y",b="2"} 1
+test_seconds_count{a="x\"y",b="2"} 2
+`
+	if string(body) != want {
+		t.Fatalf("wrong exposition\n%s", body)
+	}
+}
+
+func TestNetClient_Metrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:17045")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	tree, _ := NewMerkleTree(testMerkleHash(0x00), testMerkleHash(0x80), 2)
+	NewMerkleService(func(start, end []byte, depth int) (*MerkleTree, error) {
+		return tree, nil
+	}).RegisterServer(server)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	pm := NewPrometheusMetrics()
+	client := NewNetClient(2*time.Second, 10*time.Second,
+		WithMetrics(pm),
+		WithSigner(StaticTokenSigner("token")),
+	)
+	defer client.Shutdown()
+
+	if _, err = MerkleDiff(context.Background(), tree, client.MerkleLevels("127.0.0.1:17045", tree)); err != nil {
+		t.Fatal(err)
+	}
+	// Not served
+	if _, err = client.Topology("127.0.0.1:17045"); err == nil {
+		t.Fatal("should fail")
+	}
+
+	out := pm.Bytes()
+	for _, line := range []string{
+		`hexaring_client_rpc_duration_seconds_count{method="MerkleLevelRPC",outcome="ok"} 1`,
+		`hexaring_client_rpc_duration_seconds_count{method="TopologyRPC",outcome="unimplemented"} 1`,
+	} {
+		if !bytes.Contains(out, []byte(line)) {
+			t.Fatalf("missing %s\n%s", line, out)
+		}
+	}
+}
+
+func TestRing_Metrics(t *testing.T) {
+	r, err := initTestRing("127.0.0.1:17046")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := NewPrometheusMetrics()
+	r.metrics = pm
+
+	client := NewNetClient(2*time.Second, 10*time.Second)
+	defer client.Shutdown()
+	if _, err = client.LookupReplicated("127.0.0.1:17046", testkey, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.LookupReplicated("127.0.0.1:17046", testkey, 1000); err == nil {
+		t.Fatal("should fail with too many replicas")
+	}
+	if _, err = r.LookupReplicated(testkey, 50); err == nil {
+		t.Fatal("should fail with not enough hosts")
+	}
+	if _, err = r.ScourReplicatedKey(testkey, 1, func(vn *chord.Vnode) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	out := pm.Bytes()
+	for _, line := range []string{
+		`hexaring_server_rpc_duration_seconds_count{method="LookupReplicatedRPC",outcome="ok",replicas="1"} 1`,
+		`hexaring_server_rpc_duration_seconds_count{method="LookupReplicatedRPC",outcome="error",replicas="invalid"} 1`,
+		`hexaring_lookup_duration_seconds_count{method="LookupReplicatedHash",outcome="not_enough_hosts",replicas="50"} 1`,
+		`hexaring_scour_visited_hosts_count{method="Scour",outcome="ok"} 1`,
+	} {
+		if !bytes.Contains(out, []byte(line)) {
+			t.Fatalf("missing %s\n%s", line, out)
+		}
+	}
+}
@@ -1,55 +1,113 @@
 package hexaring
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/hexablock/go-chord"
 )
 
-type rpcOutConn struct {
-	host   string
-	conn   *grpc.ClientConn
-	client LookupRPCClient
-	used   time.Time
-}
-
 // NetClient provides RPC calls to the ring
 type NetClient struct {
-	mu   sync.RWMutex
-	pool map[string]*rpcOutConn
+	pool *connPool
 
-	maxIdle      time.Duration
 	reapInterval time.Duration
 	shutdown     int32
+
+	// TLS credentials used to dial.  Nil dials in plaintext
+	creds *TLSCredentials
+	// Adds credentials to each call.  Nil sends none
+	signer Signer
+	// Receives rpc measurements.  Nil disables them
+	metrics Metrics
+	// Creates a span for each call propagated to the server.  Nil disables tracing
+	tracer Tracer
+	// Receives structured logs.  Defaults to the slog default logger
+	logger Logger
+}
+
+// NetClientOption sets an optional NetClient setting
+type NetClientOption func(*NetClient)
+
+// WithTLS makes the client dial hosts using TLS with the given credentials
+func WithTLS(creds *TLSCredentials) NetClientOption {
+	return func(client *NetClient) {
+		client.creds = creds
+	}
+}
+
+// WithSigner makes the client add credentials to each call using the signer
+func WithSigner(signer Signer) NetClientOption {
+	return func(client *NetClient) {
+		client.signer = signer
+	}
+}
+
+// WithMetrics makes the client record the duration and outcome of each call
+func WithMetrics(m Metrics) NetClientOption {
+	return func(client *NetClient) {
+		client.metrics = m
+	}
+}
+
+// WithTracer makes the client create a span for each call and propagate its context to
+// the server
+func WithTracer(tracer Tracer) NetClientOption {
+	return func(client *NetClient) {
+		client.tracer = tracer
+	}
+}
+
+// WithLogger makes the client write its logs to the logger
+func WithLogger(logger Logger) NetClientOption {
+	return func(client *NetClient) {
+		client.logger = logger
+	}
 }
 
 // NewNetClient instantiates a new NetClient.  It takes the max connection idle
-// time as an argument
-func NewNetClient(reapInterval, maxIdle time.Duration) *NetClient {
-	cl := &NetClient{
-		pool:         make(map[string]*rpcOutConn),
-		maxIdle:      maxIdle,
-		reapInterval: reapInterval,
+// time as an argument along with any options
+func NewNetClient(reapInterval, maxIdle time.Duration, opts ...NetClientOption) *NetClient {
+	cl := &NetClient{reapInterval: reapInterval}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	if cl.logger == nil {
+		cl.logger = defaultLogger()
 	}
+	cl.pool = newConnPool(maxIdle, func(host string) (*grpc.ClientConn, error) {
+		conn, err := grpc.Dial(host, cl.dialOptions()...)
+		if err != nil {
+			cl.logger.Warn("Failed to dial", FieldPeer, host, FieldError, errField(err))
+		}
+		return conn, err
+	})
 	go cl.reapOld()
 	return cl
 }
 
 // Lookup performs a key lookup on a host
 func (client *NetClient) Lookup(host string, n int32, key []byte) ([]*chord.Vnode, error) {
+	return client.LookupCtx(context.Background(), host, n, key)
+}
+
+// LookupCtx performs a key lookup on a host using the given context for the rpc
+func (client *NetClient) LookupCtx(ctx context.Context, host string, n int32, key []byte) ([]*chord.Vnode, error) {
 	conn, err := client.getConn(host)
 	if err != nil {
 		return nil, err
 	}
 
 	req := &LookupRequest{N: n, Key: key}
-	resp, err := conn.client.LookupRPC(context.Background(), req)
+	resp, err := conn.client.LookupRPC(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -59,13 +117,18 @@ func (client *NetClient) Lookup(host string, n int32, key []byte) ([]*chord.Vnod
 
 // LookupHash performs a LookupHash on a host
 func (client *NetClient) LookupHash(host string, n int32, hash []byte) ([]*chord.Vnode, error) {
+	return client.LookupHashCtx(context.Background(), host, n, hash)
+}
+
+// LookupHashCtx performs a LookupHash on a host using the given context for the rpc
+func (client *NetClient) LookupHashCtx(ctx context.Context, host string, n int32, hash []byte) ([]*chord.Vnode, error) {
 	conn, err := client.getConn(host)
 	if err != nil {
 		return nil, err
 	}
 
 	req := &LookupRequest{N: n, Key: hash}
-	resp, err := conn.client.LookupHashRPC(context.Background(), req)
+	resp, err := conn.client.LookupHashRPC(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -76,13 +139,19 @@ func (client *NetClient) LookupHash(host string, n int32, hash []byte) ([]*chord
 
 // LookupReplicated performs LookupReplicated request on a host
 func (client *NetClient) LookupReplicated(host string, key []byte, n int32) ([]*Location, error) {
+	return client.LookupReplicatedCtx(context.Background(), host, key, n)
+}
+
+// LookupReplicatedCtx performs LookupReplicated request on a host using the given
+// context for the rpc
+func (client *NetClient) LookupReplicatedCtx(ctx context.Context, host string, key []byte, n int32) ([]*Location, error) {
 	conn, err := client.getConn(host)
 	if err != nil {
 		return nil, err
 	}
 
 	req := &LookupRequest{N: n, Key: key}
-	resp, err := conn.client.LookupReplicatedRPC(context.Background(), req)
+	resp, err := conn.client.LookupReplicatedRPC(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -92,13 +161,19 @@ func (client *NetClient) LookupReplicated(host string, key []byte, n int32) ([]*
 
 // LookupReplicatedHash lookups a hash and its replicas against the given host
 func (client *NetClient) LookupReplicatedHash(host string, hash []byte, n int32) ([]*Location, error) {
+	return client.LookupReplicatedHashCtx(context.Background(), host, hash, n)
+}
+
+// LookupReplicatedHashCtx lookups a hash and its replicas against the given host using
+// the given context for the rpc
+func (client *NetClient) LookupReplicatedHashCtx(ctx context.Context, host string, hash []byte, n int32) ([]*Location, error) {
 	conn, err := client.getConn(host)
 	if err != nil {
 		return nil, err
 	}
 
 	req := &LookupRequest{N: n, Key: hash}
-	resp, err := conn.client.LookupReplicatedHashRPC(context.Background(), req)
+	resp, err := conn.client.LookupReplicatedHashRPC(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -106,72 +181,281 @@ func (client *NetClient) LookupReplicatedHash(host string, hash []byte, n int32)
 	return resp.Locations, nil
 }
 
-// Shutdown stops reaping connections and disabled getting any new connections
-func (client *NetClient) Shutdown() {
-	atomic.StoreInt32(&client.shutdown, 1)
-	// Close all the outbound
-	client.mu.Lock()
-	for _, out := range client.pool {
-		out.conn.Close()
+// LookupHashBatch performs a LookupHash for each hash on a host in a single request.
+// A result is returned per hash in the same order.
+func (client *NetClient) LookupHashBatch(host string, n int32, hashes [][]byte) ([]*LookupResult, error) {
+	return client.LookupHashBatchCtx(context.Background(), host, n, hashes)
+}
+
+// LookupHashBatchCtx performs a LookupHashBatch on a host using the given context for
+// the rpc
+func (client *NetClient) LookupHashBatchCtx(ctx context.Context, host string, n int32, hashes [][]byte) ([]*LookupResult, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &LookupBatchRequest{N: n, Keys: hashes}
+	resp, err := conn.client.LookupHashBatchRPC(ctx, req)
+	if err != nil {
+		return nil, err
 	}
-	client.pool = nil
-	client.mu.Unlock()
+
+	return resp.Results, nil
 }
 
-func (client *NetClient) getConn(host string) (*rpcOutConn, error) {
+// LookupReplicatedBatch performs a LookupReplicated for each key on a host in a single
+// request.  A result is returned per key in the same order.
+func (client *NetClient) LookupReplicatedBatch(host string, keys [][]byte, n int32) ([]*LookupResult, error) {
+	return client.LookupReplicatedBatchCtx(context.Background(), host, keys, n)
+}
+
+// LookupReplicatedBatchCtx performs a LookupReplicatedBatch on a host using the given
+// context for the rpc
+func (client *NetClient) LookupReplicatedBatchCtx(ctx context.Context, host string, keys [][]byte, n int32) ([]*LookupResult, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &LookupBatchRequest{N: n, Keys: keys}
+	resp, err := conn.client.LookupReplicatedBatchRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Results, nil
+}
 
-	if atomic.LoadInt32(&client.shutdown) == 1 {
-		return nil, fmt.Errorf("transport is shutdown")
+// VnodeIterator iterates over the vnodes streamed by a remote scour in visit order
+type VnodeIterator struct {
+	stream interface {
+		Recv() (*chord.Vnode, error)
 	}
+	cancel context.CancelFunc
+}
 
-	// Check if we have a conn cached
-	client.mu.RLock()
-	if out, ok := client.pool[host]; ok && out != nil {
-		defer client.mu.RUnlock()
-		return out, nil
+// Next returns the next vnode.  It returns io.EOF once the scour has completed.  Any
+// other error is the error that stopped the scour.
+func (it *VnodeIterator) Next() (*chord.Vnode, error) {
+	vn, err := it.stream.Recv()
+	if err != nil {
+		it.cancel()
 	}
-	client.mu.RUnlock()
+	return vn, err
+}
+
+// Close stops the remote scour.  It must be called if Next has not returned an error.
+func (it *VnodeIterator) Close() {
+	it.cancel()
+}
+
+// ScourReplicatedKey scours the replica locations of a key and their successors on a
+// host returning an iterator over the visited vnodes
+func (client *NetClient) ScourReplicatedKey(host string, key []byte, n int32) (*VnodeIterator, error) {
+	return client.ScourReplicatedKeyCtx(context.Background(), host, key, n)
+}
 
-	// Make a new connection
-	conn, err := grpc.Dial(host, grpc.WithInsecure())
+// ScourReplicatedKeyCtx performs a ScourReplicatedKey on a host using the given context
+// for the stream
+func (client *NetClient) ScourReplicatedKeyCtx(ctx context.Context, host string, key []byte, n int32) (*VnodeIterator, error) {
+	conn, err := client.getConn(host)
 	if err != nil {
 		return nil, err
 	}
 
-	client.mu.Lock()
-	out := &rpcOutConn{
-		host:   host,
-		client: NewLookupRPCClient(conn),
-		conn:   conn,
-		used:   time.Now(),
+	ctx, cancel := context.WithCancel(ctx)
+	cancel = conn.openStream(cancel)
+	stream, err := conn.client.ScourReplicatedKeyRPC(ctx, &LookupRequest{N: n, Key: key})
+	if err != nil {
+		cancel()
+		return nil, err
 	}
-	client.pool[host] = out
-	client.mu.Unlock()
 
-	return out, nil
+	return &VnodeIterator{stream: stream, cancel: cancel}, nil
 }
 
-func (client *NetClient) reapOld() {
-	for {
-		if atomic.LoadInt32(&client.shutdown) == 1 {
-			return
+// ScourReplica scours the successors of a replica location id on a host returning an
+// iterator over the visited vnodes
+func (client *NetClient) ScourReplica(host string, locID []byte) (*VnodeIterator, error) {
+	return client.ScourReplicaCtx(context.Background(), host, locID)
+}
+
+// ScourReplicaCtx performs a ScourReplica on a host using the given context for the
+// stream
+func (client *NetClient) ScourReplicaCtx(ctx context.Context, host string, locID []byte) (*VnodeIterator, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel = conn.openStream(cancel)
+	stream, err := conn.client.ScourReplicaRPC(ctx, &LookupRequest{Key: locID})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &VnodeIterator{stream: stream, cancel: cancel}, nil
+}
+
+// ScourSector scours all nodes between the start and end hashes on a host returning an
+// iterator over the visited vnodes
+func (client *NetClient) ScourSector(host string, start, end []byte) (*VnodeIterator, error) {
+	return client.ScourSectorCtx(context.Background(), host, start, end)
+}
+
+// ScourSectorCtx performs a ScourSector on a host using the given context for the stream
+func (client *NetClient) ScourSectorCtx(ctx context.Context, host string, start, end []byte) (*VnodeIterator, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel = conn.openStream(cancel)
+	stream, err := conn.client.ScourSectorRPC(ctx, &ScourSectorRequest{Start: start, End: end})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &VnodeIterator{stream: stream, cancel: cancel}, nil
+}
+
+// Topology returns a snapshot of all vnodes in the ring from a host
+func (client *NetClient) Topology(host string) (*Topology, error) {
+	return client.TopologyCtx(context.Background(), host)
+}
+
+// TopologyCtx performs a Topology on a host using the given context for the rpc
+func (client *NetClient) TopologyCtx(ctx context.Context, host string) (*Topology, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+	return conn.client.TopologyRPC(ctx, &TopologyRequest{})
+}
+
+// MerkleLevelCtx returns the hashes of the requested nodes of a level of the tree over
+// an arc on a host
+func (client *NetClient) MerkleLevelCtx(ctx context.Context, host string, req *MerkleLevelRequest) ([][]byte, error) {
+	conn, err := client.getConn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.merkle.MerkleLevelRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Hashes, nil
+}
+
+// MerkleLevels returns a MerkleLevelFunc fetching levels of the host's tree with the same
+// arc and depth as the local tree for use with MerkleDiff
+func (client *NetClient) MerkleLevels(host string, local *MerkleTree) MerkleLevelFunc {
+	return func(ctx context.Context, level int, indexes []int) ([][]byte, error) {
+		req := &MerkleLevelRequest{
+			Start:   local.start,
+			End:     local.end,
+			Depth:   int32(local.depth),
+			Level:   int32(level),
+			Indexes: make([]int32, len(indexes)),
 		}
-		time.Sleep(client.reapInterval)
-		client.reapOnce()
+		for i, idx := range indexes {
+			req.Indexes[i] = int32(idx)
+		}
+		return client.MerkleLevelCtx(ctx, host, req)
+	}
+}
+
+// Shutdown stops reaping connections and disabled getting any new connections
+func (client *NetClient) Shutdown() {
+	atomic.StoreInt32(&client.shutdown, 1)
+	// Close all the outbound
+	client.pool.close()
+}
+
+// Stats returns the connection pool stats
+func (client *NetClient) Stats() PoolStats {
+	return client.pool.getStats()
+}
+
+func (client *NetClient) getConn(host string) (*rpcOutConn, error) {
+	return client.pool.get(host)
+}
+
+func (client *NetClient) dialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if client.creds != nil {
+		opts[0] = client.creds.dialOption()
+	}
+
+	var (
+		unary  []grpc.UnaryClientInterceptor
+		stream []grpc.StreamClientInterceptor
+	)
+	// Trace and measure first so signing is included
+	if client.tracer != nil {
+		unary = append(unary, unaryTraceInterceptor(client.tracer))
+		stream = append(stream, streamTraceInterceptor(client.tracer))
+	}
+	if client.metrics != nil {
+		unary = append(unary, unaryMetricsInterceptor(client.metrics))
+		stream = append(stream, streamMetricsInterceptor(client.metrics))
 	}
+	if client.signer != nil {
+		unary = append(unary, unarySignInterceptor(client.signer))
+		stream = append(stream, streamSignInterceptor(client.signer))
+	}
+	if len(unary) > 0 {
+		opts = append(opts,
+			grpc.WithUnaryInterceptor(chainUnaryClient(unary)),
+			grpc.WithStreamInterceptor(chainStreamClient(stream)),
+		)
+	}
+	return opts
 }
 
-func (client *NetClient) reapOnce() {
-	client.mu.Lock()
+// chainUnaryClient returns an interceptor calling the interceptors in order.  A dial
+// takes a single interceptor.
+func chainUnaryClient(ics []grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		next := invoker
+		for i := len(ics) - 1; i >= 0; i-- {
+			ic, inner := ics[i], next
+			next = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return ic(ctx, method, req, reply, cc, inner, opts...)
+			}
+		}
+		return next(ctx, method, req, reply, cc, opts...)
+	}
+}
 
-	for host, conn := range client.pool {
-		if time.Since(conn.used) > client.maxIdle {
-			conn.conn.Close()
-			delete(client.pool, host)
+// chainStreamClient returns a stream interceptor calling the interceptors in order
+func chainStreamClient(ics []grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		next := streamer
+		for i := len(ics) - 1; i >= 0; i-- {
+			ic, inner := ics[i], next
+			next = func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return ic(ctx, desc, cc, method, inner, opts...)
+			}
 		}
+		return next(ctx, desc, cc, method, opts...)
 	}
+}
 
-	client.mu.Unlock()
+func (client *NetClient) reapOld() {
+	for {
+		time.Sleep(client.reapInterval)
+		if atomic.LoadInt32(&client.shutdown) == 1 {
+			return
+		}
+		client.pool.reap()
+	}
 }
 
 // NetTransport implements the server side lookup interface
@@ -191,33 +475,230 @@ func (trans *NetTransport) RegisterServer(server *grpc.Server) {
 }
 
 // LookupRPC serves a Lookup request
-func (trans *NetTransport) LookupRPC(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
-	resp := &LookupResponse{}
-	var err error
-	_, resp.Vnodes, err = trans.ring.Lookup(int(req.N), req.Key)
+func (trans *NetTransport) LookupRPC(ctx context.Context, req *LookupRequest) (resp *LookupResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupRPC", 0)
+	defer end(&err)
+
+	resp = &LookupResponse{}
+	_, resp.Vnodes, err = trans.ring.lookup(ctx, int(req.N), req.Key)
 	return resp, err
 }
 
 // LookupHashRPC serves a LookupHash request
-func (trans *NetTransport) LookupHashRPC(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
-	resp := &LookupResponse{}
-	var err error
-	resp.Vnodes, err = trans.ring.LookupHash(int(req.N), req.Key)
+func (trans *NetTransport) LookupHashRPC(ctx context.Context, req *LookupRequest) (resp *LookupResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupHashRPC", 0)
+	defer end(&err)
+
+	resp = &LookupResponse{}
+	resp.Vnodes, err = trans.ring.lookupHash(ctx, int(req.N), req.Key)
 	return resp, err
 }
 
 // LookupReplicatedRPC serves a LookupReplicated request
-func (trans *NetTransport) LookupReplicatedRPC(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
-	resp := &LookupResponse{}
-	var err error
-	resp.Locations, err = trans.ring.LookupReplicated(req.Key, int(req.N))
+func (trans *NetTransport) LookupReplicatedRPC(ctx context.Context, req *LookupRequest) (resp *LookupResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupReplicatedRPC", req.N)
+	defer end(&err)
+
+	n, err := trans.replicas(req.N)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &LookupResponse{}
+	resp.Locations, err = trans.ring.LookupReplicatedCtx(ctx, req.Key, n)
 	return resp, err
 }
 
 // LookupReplicatedHashRPC serves a LookupReplicatedHash request
-func (trans *NetTransport) LookupReplicatedHashRPC(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
-	resp := &LookupResponse{}
-	var err error
-	resp.Locations, err = trans.ring.LookupReplicatedHash(req.Key, int(req.N))
+func (trans *NetTransport) LookupReplicatedHashRPC(ctx context.Context, req *LookupRequest) (resp *LookupResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupReplicatedHashRPC", req.N)
+	defer end(&err)
+
+	n, err := trans.replicas(req.N)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &LookupResponse{}
+	resp.Locations, err = trans.ring.LookupReplicatedHashCtx(ctx, req.Key, n)
 	return resp, err
 }
+
+// LookupHashBatchRPC serves a LookupHashBatch request.  Hashes falling in an arc that
+// has already been resolved by the batch do not incur another lookup.
+func (trans *NetTransport) LookupHashBatchRPC(ctx context.Context, req *LookupBatchRequest) (resp *LookupBatchResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupHashBatchRPC", 0)
+	defer end(&err)
+
+	if err = trans.checkBatch(req); err != nil {
+		return nil, err
+	}
+
+	vnodes, errs := trans.ring.lookupHashBatch(ctx, req.Keys, int(req.N))
+
+	resp = &LookupBatchResponse{Results: make([]*LookupResult, len(req.Keys))}
+	for i := range req.Keys {
+		res := &LookupResult{Vnodes: vnodes[i]}
+		if errs[i] != nil {
+			res.Error = errs[i].Error()
+		}
+		resp.Results[i] = res
+	}
+
+	return resp, nil
+}
+
+// LookupReplicatedBatchRPC serves a LookupReplicatedBatch request.  Vertex lookups are
+// shared between all keys in the batch.
+func (trans *NetTransport) LookupReplicatedBatchRPC(ctx context.Context, req *LookupBatchRequest) (resp *LookupBatchResponse, err error) {
+	ctx, end := trans.instrument(ctx, "LookupReplicatedBatchRPC", req.N)
+	defer end(&err)
+
+	if err = trans.checkBatch(req); err != nil {
+		return nil, err
+	}
+	n, err := trans.replicas(req.N)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(req.Keys))
+	for i, k := range req.Keys {
+		hashes[i] = trans.ring.hashKey(k)
+	}
+	locs, errs := trans.ring.lookupReplicatedHashBatch(ctx, hashes, n)
+
+	resp = &LookupBatchResponse{Results: make([]*LookupResult, len(req.Keys))}
+	for i := range req.Keys {
+		res := &LookupResult{Locations: locs[i]}
+		if errs[i] != nil {
+			res.Error = errs[i].Error()
+		}
+		resp.Results[i] = res
+	}
+
+	return resp, nil
+}
+
+// ScourReplicatedKeyRPC serves a ScourReplicatedKey request streaming each vnode as it
+// is visited
+func (trans *NetTransport) ScourReplicatedKeyRPC(req *LookupRequest, stream LookupRPC_ScourReplicatedKeyRPCServer) (err error) {
+	ctx, end := trans.instrument(stream.Context(), "ScourReplicatedKeyRPC", req.N)
+	defer end(&err)
+
+	n, err := trans.replicas(req.N)
+	if err != nil {
+		return err
+	}
+
+	_, err = trans.ring.ScourReplicatedKeyCtx(ctx, req.Key, n, stream.Send)
+	return err
+}
+
+// ScourReplicaRPC serves a ScourReplica request streaming each vnode as it is visited
+func (trans *NetTransport) ScourReplicaRPC(req *LookupRequest, stream LookupRPC_ScourReplicaRPCServer) (err error) {
+	ctx, end := trans.instrument(stream.Context(), "ScourReplicaRPC", 0)
+	defer end(&err)
+
+	_, err = trans.ring.ScourReplicaCtx(ctx, req.Key, stream.Send)
+	return err
+}
+
+// ScourSectorRPC serves a ScourSector request streaming each vnode as it is visited
+func (trans *NetTransport) ScourSectorRPC(req *ScourSectorRequest, stream LookupRPC_ScourSectorRPCServer) (err error) {
+	ctx, end := trans.instrument(stream.Context(), "ScourSectorRPC", 0)
+	defer end(&err)
+
+	_, err = trans.ring.ScourSectorCtx(ctx, req.Start, req.End, stream.Send)
+	return err
+}
+
+// TopologyRPC serves a snapshot of all vnodes in the ring
+func (trans *NetTransport) TopologyRPC(ctx context.Context, req *TopologyRequest) (topo *Topology, err error) {
+	ctx, end := trans.instrument(ctx, "TopologyRPC", 0)
+	defer end(&err)
+
+	return trans.ring.TopologyCtx(ctx)
+}
+
+// instrument starts the span of a request continuing the caller's trace.  The returned
+// function converts the error with lookupStatus, records the request metrics and ends
+// the span, and is deferred by each handler.  The replica count of replicated requests
+// is resolved and validated with replicas so a client cannot create unbounded label
+// values.  Rejected counts are labelled as invalid.
+func (trans *NetTransport) instrument(ctx context.Context, method string, reqN int32) (context.Context, func(*error)) {
+	start := time.Now()
+
+	var n int
+	if strings.Contains(method, "Replicated") {
+		var err error
+		if n, err = trans.replicas(reqN); err != nil {
+			n = invalidReplicas
+		}
+	}
+
+	ctx, span := trans.ring.tracer.Start(extractTrace(ctx, trans.ring.tracer), "hexaring.LookupRPC/"+method)
+	if n > 0 {
+		span.SetAttribute(LabelReplicas, n)
+	}
+
+	return ctx, func(err *error) {
+		*err = lookupStatus(*err)
+		trans.ring.metrics.Observe(MetricServerRPCDuration, rpcLabels(method, n, *err), time.Since(start).Seconds())
+		endSpan(span, *err)
+	}
+}
+
+// lookupStatus converts the error of a lookup to a grpc status error clients can act on.
+// Context errors and errors reporting a Timeout, such as those of the chord calls made by
+// the lookup, become Canceled or DeadlineExceeded so a client may retry on another
+// member.  Other errors are returned as is and surface as Unknown.
+func lookupStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case err == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case err == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	var te interface {
+		Timeout() bool
+	}
+	if errors.As(err, &te) && te.Timeout() {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return err
+}
+
+// checkBatch validates the number of keys in a batch request
+func (trans *NetTransport) checkBatch(req *LookupBatchRequest) error {
+	if len(req.Keys) == 0 {
+		return fmt.Errorf("no keys in batch")
+	}
+	if max := trans.ring.conf.LookupService.MaxBatchKeys; max > 0 && len(req.Keys) > max {
+		return fmt.Errorf("too many keys in batch: %d > %d", len(req.Keys), max)
+	}
+	return nil
+}
+
+// replicas returns the replica count for the request applying the lookup service
+// default and limit
+func (trans *NetTransport) replicas(reqN int32) (int, error) {
+	conf := trans.ring.conf
+
+	n := int(reqN)
+	if n <= 0 {
+		return conf.LookupService.DefaultReplicas, nil
+	}
+	if max := conf.maxReplicas(); n > max {
+		return 0, fmt.Errorf("too many replicas requested: %d > %d", n, max)
+	}
+	return n, nil
+}
@@ -2,8 +2,16 @@ package hexaring
 
 import (
 	"crypto/sha1"
+	"fmt"
+	"io"
 	"testing"
 	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	chord "github.com/hexablock/go-chord"
 )
 
 func TestNetTransport(t *testing.T) {
@@ -60,6 +68,44 @@ func TestNetTransport(t *testing.T) {
 		t.Fatal("should have 3 vnodes")
 	}
 
+	keys := [][]byte{testkey, []byte("key1"), []byte("key2")}
+	results, err := client.LookupReplicatedBatch("127.0.0.1:12345", keys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatal("should have 3 results")
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			t.Fatal(res.Error)
+		}
+		if len(res.Locations) != 2 {
+			t.Fatal("should have 2 locations")
+		}
+	}
+	if !equalBytes(results[0].Locations[0].ID, locs1[0].ID) {
+		t.Fatal("batch location mismatch")
+	}
+
+	hresults, err := client.LookupHashBatch("127.0.0.1:23456", 3, [][]byte{sh[:], locs1[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hresults) != 2 || len(hresults[0].Vnodes) != 3 || len(hresults[1].Vnodes) != 3 {
+		t.Fatal("should have 3 vnodes per hash")
+	}
+
+	if _, err = client.LookupReplicatedBatch("127.0.0.1:12345", nil, 2); err == nil {
+		t.Fatal("should fail with no keys")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = client.LookupReplicatedCtx(ctx, "127.0.0.1:12345", testkey, 2); err == nil {
+		t.Fatal("should fail with cancelled context")
+	}
+
 	// Allow reap
 	<-time.After(3 * time.Second)
 
@@ -70,3 +116,105 @@ func TestNetTransport(t *testing.T) {
 	}
 
 }
+
+func TestNetClient_Scour(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:13345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	if _, err = initTestRing("127.0.0.1:24456", "127.0.0.1:13345"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	if _, err = initTestRing("127.0.0.1:24466", "127.0.0.1:13345"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(200 * time.Millisecond)
+
+	key := []byte("some-data")
+	var local []*chord.Vnode
+	r1.ScourReplicatedKey(key, 3, func(vn *chord.Vnode) error {
+		local = append(local, vn)
+		return nil
+	})
+
+	client := NewNetClient(2*time.Second, 10*time.Second)
+	defer client.Shutdown()
+
+	it, err := client.ScourReplicatedKey("127.0.0.1:13345", key, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var remote []*chord.Vnode
+	for {
+		vn, err := it.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		remote = append(remote, vn)
+	}
+
+	if len(remote) != len(local) {
+		t.Fatal("scour count mismatch", len(remote), len(local))
+	}
+	for i := range local {
+		if local[i].Host != remote[i].Host {
+			t.Fatal("visit order mismatch")
+		}
+	}
+
+	locs, _ := r1.LookupReplicated(key, 3)
+	it, err = client.ScourReplica("127.0.0.1:13345", locs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vn, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vn.Host != locs[0].Host() {
+		t.Fatal("should start at the replica host")
+	}
+	it.Close()
+
+	it, err = client.ScourSector("127.0.0.1:13345", locs[1].ID, locs[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = it.Next(); err == nil || err == io.EOF {
+		t.Fatal("should fail with nothing to scour")
+	}
+}
+
+// timeoutError is a net.Error style timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestLookupStatus(t *testing.T) {
+	cases := map[error]codes.Code{
+		context.Canceled:                          codes.Canceled,
+		context.DeadlineExceeded:                  codes.DeadlineExceeded,
+		timeoutError{}:                            codes.DeadlineExceeded,
+		fmt.Errorf("lookup: %w", timeoutError{}):  codes.DeadlineExceeded,
+		fmt.Errorf("peer timeout.example down"):   codes.Unknown,
+		status.Errorf(codes.Unavailable, "down"):  codes.Unavailable,
+		errNotEnoughHosts:                         codes.Unknown,
+		fmt.Errorf("too many replicas requested"): codes.Unknown,
+	}
+	for err, want := range cases {
+		if got := status.Code(lookupStatus(err)); got != want {
+			t.Errorf("%v: want %v got %v", err, want, got)
+		}
+	}
+	if lookupStatus(nil) != nil {
+		t.Fatal("should be nil")
+	}
+}
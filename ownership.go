@@ -0,0 +1,222 @@
+package hexaring
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// ErrOwnershipPlacement is returned when subscribing to ownership events the ring's
+// placement does not allow computing
+var ErrOwnershipPlacement = errors.New("ownership events not supported by the placement")
+
+// OwnershipChange is the direction of an ownership change
+type OwnershipChange int
+
+const (
+	// OwnershipGained means the local host now owns the arc
+	OwnershipGained OwnershipChange = iota
+	// OwnershipLost means the local host no longer owns the arc
+	OwnershipLost
+)
+
+func (c OwnershipChange) String() string {
+	if c == OwnershipGained {
+		return "gained"
+	}
+	return "lost"
+}
+
+// OwnershipEvent describes an arc of key hashes whose ownership moved to or away from
+// the local host
+type OwnershipEvent struct {
+	Change OwnershipChange
+	// Replica index the change applies to.  Zero is the primary
+	Replica int
+	// Arc of key hashes from Start exclusive to End inclusive
+	Start []byte
+	End   []byte
+	// Local vnode whose ownership changed
+	Local *chord.Vnode
+	// Remote vnode the arc moved from or to
+	Remote *chord.Vnode
+}
+
+// Contains returns true if the key hash is in the arc of the event
+func (e *OwnershipEvent) Contains(hash []byte) bool {
+	return !bytes.Equal(hash, e.Start) && inArc(e.Start, e.End, hash)
+}
+
+// OwnershipSubscription receives ownership events until closed
+type OwnershipSubscription struct {
+	// Channel events are delivered on.  It is closed when the subscription is closed
+	C <-chan *OwnershipEvent
+
+	c        chan *OwnershipEvent
+	replicas int
+	dropped  uint64
+	notifier *ownershipNotifier
+}
+
+// Dropped returns the number of events dropped because the channel was full
+func (sub *OwnershipSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// Close stops delivery and closes the channel
+func (sub *OwnershipSubscription) Close() {
+	sub.notifier.remove(sub)
+}
+
+// ownershipNotifier turns predecessor changes into ownership events for subscribers
+type ownershipNotifier struct {
+	mu   sync.RWMutex
+	subs map[*OwnershipSubscription]struct{}
+}
+
+func newOwnershipNotifier() *ownershipNotifier {
+	return &ownershipNotifier{subs: make(map[*OwnershipSubscription]struct{})}
+}
+
+func (on *ownershipNotifier) add(sub *OwnershipSubscription) {
+	on.mu.Lock()
+	on.subs[sub] = struct{}{}
+	on.mu.Unlock()
+}
+
+func (on *ownershipNotifier) remove(sub *OwnershipSubscription) {
+	on.mu.Lock()
+	if _, ok := on.subs[sub]; ok {
+		delete(on.subs, sub)
+		close(sub.c)
+	}
+	on.mu.Unlock()
+}
+
+// newPredecessor is registered with the ring delegate
+func (on *ownershipNotifier) newPredecessor(local, remoteNew, remotePrev *chord.Vnode) {
+	primary := primaryOwnershipEvent(local, remoteNew, remotePrev)
+	if primary == nil {
+		return
+	}
+
+	on.mu.RLock()
+	defer on.mu.RUnlock()
+
+	for sub := range on.subs {
+		for i := 0; i < sub.replicas; i++ {
+			ev := *primary
+			ev.Replica = i
+			ev.Start, ev.End = replicaArc(primary.Start, primary.End, i, sub.replicas)
+
+			select {
+			case sub.c <- &ev:
+			default:
+				atomic.AddUint64(&sub.dropped, 1)
+			}
+		}
+	}
+}
+
+// primaryOwnershipEvent returns the primary ownership change of the local vnode given
+// its new and previous predecessor.  A new predecessor within the previously owned arc
+// takes part of the arc.  One before the previous predecessor means the previous one
+// left and its arc is now owned locally.  Changes between vnodes of the same host are
+// ignored as the host still owns the arc.
+func primaryOwnershipEvent(local, remoteNew, remotePrev *chord.Vnode) *OwnershipEvent {
+	if local == nil || remoteNew == nil || remotePrev == nil || bytes.Equal(remoteNew.Id, remotePrev.Id) {
+		return nil
+	}
+
+	if inArc(remotePrev.Id, local.Id, remoteNew.Id) {
+		if remoteNew.Host == local.Host {
+			return nil
+		}
+		return &OwnershipEvent{
+			Change: OwnershipLost,
+			Start:  remotePrev.Id,
+			End:    remoteNew.Id,
+			Local:  local,
+			Remote: remoteNew,
+		}
+	}
+
+	if remotePrev.Host == local.Host {
+		return nil
+	}
+	return &OwnershipEvent{
+		Change: OwnershipGained,
+		Start:  remoteNew.Id,
+		End:    remotePrev.Id,
+		Local:  local,
+		Remote: remotePrev,
+	}
+}
+
+// replicaArc returns the arc of key hashes whose replica i of n falls in the given arc.
+// This is the inverse of the equidistant vertexes computed by CalculateRingVertexBytes
+// i.e. the arc shifted back by i sections.
+func replicaArc(start, end []byte, i, n int) ([]byte, []byte) {
+	if i == 0 {
+		return start, end
+	}
+	return shiftHash(start, i, n), shiftHash(end, i, n)
+}
+
+func shiftHash(hash []byte, i, n int) []byte {
+	var circum big.Int
+	circum.Exp(big.NewInt(2), big.NewInt(int64(len(hash))*8), nil)
+
+	width := new(big.Int).Div(&circum, big.NewInt(int64(n)))
+	shift := new(big.Int).Mul(width, big.NewInt(int64(i)))
+
+	v := new(big.Int).SetBytes(hash)
+	v.Sub(v, shift)
+	v.Mod(v, &circum)
+
+	b := v.Bytes()
+	if len(b) < len(hash) {
+		b = append(make([]byte, len(hash)-len(b)), b...)
+	}
+	return b
+}
+
+// SubscribeOwnership returns a subscription receiving events for arcs whose ownership
+// moved to or away from the local host.  Events are generated for the primary and, if
+// replicas > 1, each replica index.  Events are dropped if the buffer is full.
+//
+// Events assume the primary is the first successor of the hash and replica i the first
+// successor of vertex i.  This only holds for the equidistant and successor placements
+// without a weight key, and for replicas > 1 only for the equidistant placement without
+// failure domains.  ErrOwnershipPlacement is returned for any other placement.  Even
+// then a replica moves to the next successor when its vertex lands on a host already
+// holding a replica, which the events do not reflect.
+func (r *Ring) SubscribeOwnership(replicas, buffer int) (*OwnershipSubscription, error) {
+	if replicas < 1 {
+		replicas = 1
+	}
+	if !ownershipSupported(r.placement, replicas) {
+		return nil, ErrOwnershipPlacement
+	}
+
+	c := make(chan *OwnershipEvent, buffer)
+	sub := &OwnershipSubscription{C: c, c: c, replicas: replicas, notifier: r.ownership}
+	r.ownership.add(sub)
+	return sub, nil
+}
+
+// ownershipSupported returns true if ownership events for the replica count match where
+// the placement puts replicas
+func ownershipSupported(p PlacementStrategy, replicas int) bool {
+	switch pl := p.(type) {
+	case *EquidistantPlacement:
+		return pl.weightKey == "" && (replicas == 1 || len(pl.domains) == 0)
+	case *SuccessorPlacement:
+		return pl.weightKey == "" && replicas == 1
+	}
+	return false
+}
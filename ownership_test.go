@@ -0,0 +1,136 @@
+package hexaring
+
+import (
+	"crypto/sha1"
+	"math/big"
+	"testing"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func testIDVnode(host string, id byte) *chord.Vnode {
+	b := make([]byte, sha1.Size)
+	b[0] = id
+	return &chord.Vnode{Id: b, Host: host}
+}
+
+func TestPrimaryOwnershipEvent(t *testing.T) {
+	var (
+		local = testIDVnode("h1", 100)
+		prev  = testIDVnode("h2", 50)
+		join  = testIDVnode("h3", 70)
+		early = testIDVnode("h3", 20)
+	)
+
+	// Joined between the previous predecessor and us
+	ev := primaryOwnershipEvent(local, join, prev)
+	if ev == nil || ev.Change != OwnershipLost || ev.Remote != join {
+		t.Fatalf("should lose arc %+v", ev)
+	}
+	if !ev.Contains(join.Id) || ev.Contains(prev.Id) || ev.Contains(local.Id) {
+		t.Fatal("wrong arc")
+	}
+
+	// Previous predecessor left
+	ev = primaryOwnershipEvent(local, early, prev)
+	if ev == nil || ev.Change != OwnershipGained || ev.Remote != prev {
+		t.Fatalf("should gain arc %+v", ev)
+	}
+	if !ev.Contains(prev.Id) || ev.Contains(early.Id) {
+		t.Fatal("wrong arc")
+	}
+
+	// Same host
+	if ev = primaryOwnershipEvent(local, testIDVnode("h1", 70), prev); ev != nil {
+		t.Fatal("same host should not change ownership")
+	}
+	if ev = primaryOwnershipEvent(local, join, nil); ev != nil {
+		t.Fatal("no previous predecessor should not change ownership")
+	}
+}
+
+func TestReplicaArc(t *testing.T) {
+	var (
+		start = testIDVnode("", 10).Id
+		end   = testIDVnode("", 30).Id
+	)
+
+	for i := 0; i < 3; i++ {
+		s, e := replicaArc(start, end, i, 3)
+		ev := &OwnershipEvent{Start: s, End: e}
+
+		// A key in the replica arc has its ith vertex in the primary arc
+		k := new(big.Int).SetBytes(e)
+		k.Sub(k, big.NewInt(1))
+		key := append(make([]byte, sha1.Size-len(k.Bytes())), k.Bytes()...)
+
+		if !ev.Contains(key) {
+			t.Fatal("key should be in the replica arc")
+		}
+		vertex := CalculateRingVertexBytes(key, 3)[i]
+		if !(&OwnershipEvent{Start: start, End: end}).Contains(vertex) {
+			t.Fatalf("vertex %d should be in the primary arc %x", i, vertex)
+		}
+	}
+}
+
+func TestRing_SubscribeOwnership(t *testing.T) {
+	r := New(fastConf("127.0.0.1:47781"), NewInMemPeerStore())
+
+	sub, err := r.SubscribeOwnership(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, _ := r.SubscribeOwnership(1, 0)
+
+	local := testIDVnode("127.0.0.1:47781", 100)
+	r.delegate.NewPredecessor(local, testIDVnode("h2", 70), testIDVnode("h2", 50))
+
+	for i := 0; i < 3; i++ {
+		ev := <-sub.C
+		if ev.Replica != i || ev.Change != OwnershipLost {
+			t.Fatalf("wrong event %+v", ev)
+		}
+	}
+	if full.Dropped() != 1 {
+		t.Fatal("event should be dropped")
+	}
+
+	sub.Close()
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("channel should be closed")
+	}
+	full.Close()
+}
+
+func TestOwnershipSupported(t *testing.T) {
+	weighted := NewEquidistantPlacement()
+	weighted.SetWeightKey("weight")
+
+	cases := []struct {
+		placement PlacementStrategy
+		replicas  int
+		ok        bool
+	}{
+		{NewEquidistantPlacement(), 3, true},
+		{NewEquidistantPlacement("zone"), 1, true},
+		{NewEquidistantPlacement("zone"), 3, false},
+		{weighted, 1, false},
+		{NewSuccessorPlacement(), 1, true},
+		{NewSuccessorPlacement(), 2, false},
+		{NewRendezvousPlacement(sha1.New), 1, false},
+	}
+	for i, c := range cases {
+		if ownershipSupported(c.placement, c.replicas) != c.ok {
+			t.Errorf("case %d: want %v", i, c.ok)
+		}
+	}
+
+	conf := fastConf("127.0.0.1:47784")
+	conf.FailureDomains = []string{"zone"}
+	r := New(conf, NewInMemPeerStore())
+	if _, err := r.SubscribeOwnership(3, 1); err != ErrOwnershipPlacement {
+		t.Fatal("should reject replica events with failure domains", err)
+	}
+}
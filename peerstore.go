@@ -24,13 +24,27 @@ type PeerStore interface {
 
 // InMemPeerStore implements an in-memory PeerStore interface
 type InMemPeerStore struct {
-	mu    sync.RWMutex
-	peers []*Peer
+	mu     sync.RWMutex
+	peers  []*Peer
+	logger Logger
 }
 
 // NewInMemPeerStore instantiates a new in-memory peer store
 func NewInMemPeerStore() *InMemPeerStore {
-	return &InMemPeerStore{peers: make([]*Peer, 0)}
+	return &InMemPeerStore{peers: make([]*Peer, 0), logger: defaultLogger()}
+}
+
+// SetLogger sets the logger for peer changes.  It must be called before the store is
+// used.  The slog default logger is used if not set.
+func (ps *InMemPeerStore) SetLogger(logger Logger) {
+	ps.logger = logger
+}
+
+func (ps *InMemPeerStore) log() Logger {
+	if ps.logger == nil {
+		return defaultLogger()
+	}
+	return ps.logger
 }
 
 // Peers returns a slice of all known peers
@@ -53,6 +67,7 @@ func (ps *InMemPeerStore) RemovePeer(peer string) {
 			if p.Address == peer {
 				ps.peers = append(ps.peers[:i], ps.peers[i+1:]...)
 				ps.mu.Unlock()
+				ps.log().Debug("Removed peer", FieldPeer, peer)
 				return
 			}
 		}
@@ -60,6 +75,7 @@ func (ps *InMemPeerStore) RemovePeer(peer string) {
 		if ps.peers[0].Address == peer {
 			ps.peers = []*Peer{}
 			ps.mu.Unlock()
+			ps.log().Debug("Removed peer", FieldPeer, peer)
 			return
 		}
 	}
@@ -86,6 +102,7 @@ func (ps *InMemPeerStore) AddPeer(peer string) bool {
 	ps.mu.Lock()
 	ps.peers = append(ps.peers, p)
 	ps.mu.Unlock()
+	ps.log().Debug("Added peer", FieldPeer, peer)
 
 	return true
 }
@@ -133,7 +150,10 @@ func NewPeerJSONStore(filename string) (*PeerJSONStore, error) {
 // AddPeer adds a peer to the json store
 func (ps *PeerJSONStore) AddPeer(peer string) bool {
 	if ps.InMemPeerStore.AddPeer(peer) {
-		ps.Commit()
+		if err := ps.Commit(); err != nil {
+			ps.log().Error("Failed to write peer store", FieldPeer, peer, "file", ps.filename,
+				FieldError, errField(err))
+		}
 		return true
 	}
 	return false
@@ -53,3 +53,13 @@ func TestPeerStore(t *testing.T) {
 		t.Fatal("should have 0 peers")
 	}
 }
+
+func TestInMemPeerStore_ZeroValue(t *testing.T) {
+	var ps InMemPeerStore
+	ps.AddPeer("peer")
+	ps.SetLogger(nil)
+	ps.RemovePeer("peer")
+	if len(ps.Peers()) != 0 {
+		t.Fatal("should have 0 peers")
+	}
+}
@@ -0,0 +1,391 @@
+package hexaring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+var errNotEnoughHosts = errors.New("not enough hosts found")
+
+// SuccessorLookup returns the successors of a hash on the ring
+type SuccessorLookup func(ctx context.Context, hash []byte) ([]*chord.Vnode, error)
+
+// PlacementStrategy chooses the locations of the n replicas for a hash.  Each location
+// returned must be on a unique host.  It returns an error if the lookup fails or not
+// enough unique hosts are found.
+type PlacementStrategy interface {
+	Place(ctx context.Context, lookup SuccessorLookup, hash []byte, n int) (LocationSet, error)
+}
+
+// EquidistantPlacement places replicas at n equidistant vertexes around the ring
+// starting from the hash, choosing a unique host from the successors of each vertex.
+// Each location ID is its vertex.
+type EquidistantPlacement struct {
+	replicaSelector
+}
+
+// NewEquidistantPlacement instantiates an EquidistantPlacement spreading replicas across
+// the given failure domain meta keys
+func NewEquidistantPlacement(failureDomains ...string) *EquidistantPlacement {
+	return &EquidistantPlacement{replicaSelector{domains: failureDomains}}
+}
+
+// Place looks up the successors of each vertex in its own go-routine.  It returns the
+// context error as soon as the context is done without waiting on in-flight lookups.
+func (p *EquidistantPlacement) Place(ctx context.Context, lookup SuccessorLookup, hash []byte, n int) (LocationSet, error) {
+	hashes := CalculateRingVertexBytes(hash, int64(n))
+	type result struct {
+		locs []*Location
+		err  error
+	}
+	// Buffered so in-flight go-routines never block once we have returned
+	out := make(chan result, n)
+
+	for i, h := range hashes {
+		// Lookup successors for the replicated hash with the maximum allowable
+		// successors.
+		go func(idx int, hsh []byte) {
+
+			vs, err := lookup(ctx, hsh)
+			if err != nil || len(vs) == 0 {
+				if err != nil && err != ctx.Err() {
+					p.log().Error("Lookup failed", FieldHash, hex.EncodeToString(hsh), FieldError, errField(err))
+				}
+				out <- result{err: err}
+				return
+			}
+
+			locs := make([]*Location, len(vs))
+			for j, v := range vs {
+				locs[j] = &Location{ID: hsh, Vnode: v, Index: int32(j), Priority: int32(idx)}
+			}
+			out <- result{locs: locs}
+
+		}(i, h)
+
+	}
+
+	locations := make([][]*Location, n)
+	// Sort by priority
+	for i := 0; i < n; i++ {
+		var res result
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res = <-out:
+		}
+
+		la := res.locs
+		if la == nil {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			// Let callers tell a failed lookup e.g. a timeout from too few hosts
+			if res.err != nil {
+				return nil, res.err
+			}
+			return nil, errNotEnoughHosts
+		}
+
+		pri := la[0].Priority
+		locations[pri] = la
+	}
+
+	// Select a unique host per location by priority spreading across failure domains
+	locs := p.selectLocations(locations)
+	// Make sure we have the requested count
+	for i, l := range locs {
+		if l == nil {
+			return locs[:i], errNotEnoughHosts
+		}
+	}
+
+	return locs, nil
+}
+
+// SuccessorPlacement places replicas on the first n unique hosts in the successor list
+// of the hash.  Every location ID is the hash itself.  Placement only depends on one
+// lookup but n is limited by the number of successors.
+type SuccessorPlacement struct {
+	replicaSelector
+}
+
+// NewSuccessorPlacement instantiates a SuccessorPlacement spreading replicas across the
+// given failure domain meta keys
+func NewSuccessorPlacement(failureDomains ...string) *SuccessorPlacement {
+	return &SuccessorPlacement{replicaSelector{domains: failureDomains}}
+}
+
+// Place looks up the successors of the hash and selects n unique hosts in order
+func (p *SuccessorPlacement) Place(ctx context.Context, lookup SuccessorLookup, hash []byte, n int) (LocationSet, error) {
+	vs, err := lookup(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return p.placeFromSuccessors(hash, vs, n)
+}
+
+// RendezvousPlacement places replicas using rendezvous (highest random weight) hashing
+// over the successor list of the hash.  Each successor is weighted by the hash of its
+// id and the key hash and the n highest weighted unique hosts are chosen.  Every
+// location ID is the hash itself.  Compared to SuccessorPlacement, a node joining or
+// leaving moves fewer replicas within the successor list.
+type RendezvousPlacement struct {
+	replicaSelector
+	hashFunc func() hash.Hash
+}
+
+// NewRendezvousPlacement instantiates a RendezvousPlacement using the given hash
+// function to weight successors and spreading replicas across the given failure domain
+// meta keys
+func NewRendezvousPlacement(hashFunc func() hash.Hash, failureDomains ...string) *RendezvousPlacement {
+	return &RendezvousPlacement{
+		replicaSelector: replicaSelector{domains: failureDomains},
+		hashFunc:        hashFunc,
+	}
+}
+
+// Place looks up the successors of the hash and selects the n highest weighted unique
+// hosts
+func (p *RendezvousPlacement) Place(ctx context.Context, lookup SuccessorLookup, hash []byte, n int) (LocationSet, error) {
+	vs, err := lookup(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[*chord.Vnode][]byte, len(vs))
+	for _, vn := range vs {
+		h := p.hashFunc()
+		h.Write(vn.Id)
+		h.Write(hash)
+		weights[vn] = h.Sum(nil)
+	}
+
+	// Order successors by weight keeping their original index
+	sorted := make([]*chord.Vnode, len(vs))
+	copy(sorted, vs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(weights[sorted[i]], weights[sorted[j]]) > 0
+	})
+
+	locs, err := p.placeFromSuccessors(hash, sorted, n)
+	if err != nil {
+		return locs, err
+	}
+	// Index refers to the position in the successor list
+	for _, l := range locs {
+		for j, vn := range vs {
+			if vn == l.Vnode {
+				l.Index = int32(j)
+				break
+			}
+		}
+	}
+	return locs, nil
+}
+
+// replicaSelector selects unique hosts from candidate successors spreading them across
+// failure domains and optionally weighting them by capacity.  It is shared by the
+// built-in placement strategies.
+type replicaSelector struct {
+	domains   []string
+	weightKey string
+	logger    Logger
+}
+
+// SetWeightKey sets the vnode meta key holding the capacity weight of a node.  When set,
+// equally ranked successors are chosen in proportion to their weight rather than by
+// proximity so larger nodes take more replicas.  Nodes without a weight default to 1.
+func (s *replicaSelector) SetWeightKey(key string) {
+	s.weightKey = key
+}
+
+// SetLogger sets the logger for lookup failures.  The slog default logger is used if not
+// set.
+func (s *replicaSelector) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+func (s *replicaSelector) log() Logger {
+	if s.logger == nil {
+		return defaultLogger()
+	}
+	return s.logger
+}
+
+func (s *replicaSelector) selectLocations(candidates [][]*Location) LocationSet {
+	return selectLocations(candidates, s.domains, s.weightKey)
+}
+
+// placeFromSuccessors selects n unique hosts from the ordered successors of a hash
+func (s *replicaSelector) placeFromSuccessors(hash []byte, vs []*chord.Vnode, n int) (LocationSet, error) {
+	// Every replica chooses from the same ordered candidates
+	candidates := make([][]*Location, n)
+	for i := range candidates {
+		candidates[i] = make([]*Location, len(vs))
+		for j, vn := range vs {
+			candidates[i][j] = &Location{ID: hash, Vnode: vn, Index: int32(j), Priority: int32(i)}
+		}
+	}
+
+	locs := s.selectLocations(candidates)
+	for i, l := range locs {
+		if l == nil {
+			return locs[:i], errNotEnoughHosts
+		}
+	}
+	return locs, nil
+}
+
+// vnodeMeta returns the decoded metadata of a vnode.  An empty Meta is returned if the
+// vnode has none or it cannot be decoded.
+func vnodeMeta(vn *chord.Vnode) chord.Meta {
+	meta := chord.Meta{}
+	if vn == nil || len(vn.Meta) == 0 {
+		return meta
+	}
+	if err := meta.UnmarshalBinary(vn.Meta); err != nil {
+		return chord.Meta{}
+	}
+	return meta
+}
+
+// failureDomainPath returns the failure domain labels of a vnode for each of the given
+// meta keys followed by the host.  Each element is qualified by its parents so a rack
+// with the same name in two zones is treated as two racks.
+func failureDomainPath(vn *chord.Vnode, domains []string) []string {
+	path := make([]string, len(domains)+1)
+
+	var meta chord.Meta
+	if len(domains) > 0 {
+		meta = vnodeMeta(vn)
+	}
+
+	parent := ""
+	for i, d := range domains {
+		parent += "/" + strings.Replace(string(meta[d]), "/", "_", -1)
+		path[i] = parent
+	}
+	path[len(domains)] = parent + "/" + vn.Host
+
+	return path
+}
+
+// domainSet tracks the failure domains used by selected locations at each level
+type domainSet []map[string]struct{}
+
+func newDomainSet(levels int) domainSet {
+	ds := make(domainSet, levels)
+	for i := range ds {
+		ds[i] = map[string]struct{}{}
+	}
+	return ds
+}
+
+// rank returns the widest level at which the path is not yet used or -1 if the path is
+// fully used i.e. the host has already been selected.
+func (ds domainSet) rank(path []string) int {
+	for i, p := range path {
+		if _, ok := ds[i][p]; !ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ds domainSet) add(path []string) {
+	for i, p := range path {
+		ds[i][p] = struct{}{}
+	}
+}
+
+// selectLocations selects one location per vertex from the successors of each vertex in
+// priority order.  A successor in a new failure domain at the widest level is
+// preferred e.g. a new zone over a new rack over a new host.  Between equally ranked
+// successors the closest one is chosen, or if a weight key is given, the one with the
+// highest weighted rendezvous score.  With no domains this is the first successor on a
+// host not yet selected.  Vertexes with no eligible successor are left nil.
+func selectLocations(candidates [][]*Location, domains []string, weightKey string) LocationSet {
+	var (
+		locs = make(LocationSet, len(candidates))
+		used = newDomainSet(len(domains) + 1)
+	)
+
+	for i, cands := range candidates {
+		var (
+			best      = -1
+			bestRank  = -1
+			bestScore float64
+			bestPath  []string
+		)
+		for j, c := range cands {
+			path := failureDomainPath(c.Vnode, domains)
+			rk := used.rank(path)
+			if rk < 0 {
+				continue
+			}
+
+			if weightKey == "" {
+				if best < 0 || rk < bestRank {
+					best, bestRank, bestPath = j, rk, path
+					if rk == 0 {
+						break
+					}
+				}
+				continue
+			}
+
+			score := weightedScore(c, vnodeWeight(c.Vnode, weightKey))
+			if best < 0 || rk < bestRank || (rk == bestRank && score > bestScore) {
+				best, bestRank, bestScore, bestPath = j, rk, score, path
+			}
+		}
+
+		if best >= 0 {
+			locs[i] = cands[best]
+			used.add(bestPath)
+		}
+	}
+
+	return locs
+}
+
+// vnodeWeight returns the capacity weight advertised in the vnode meta under the key.
+// It defaults to 1 if not set or invalid.  Negative weights are treated as 0.
+func vnodeWeight(vn *chord.Vnode, key string) float64 {
+	v, ok := vnodeMeta(vn)[key]
+	if !ok {
+		return 1
+	}
+	w, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return 1
+	}
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// weightedScore returns the weighted rendezvous score of a location i.e. w / -ln(u)
+// where u is uniform in (0, 1) derived from the vnode and location ids.  Choosing the
+// highest score selects each candidate with a probability proportional to its weight.
+func weightedScore(loc *Location, weight float64) float64 {
+	h := fnv.New64a()
+	h.Write(loc.Vnode.Id)
+	h.Write(loc.ID)
+	u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+
+	return weight / -math.Log(u)
+}
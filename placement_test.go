@@ -0,0 +1,204 @@
+package hexaring
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func testVnode(host, zone, rack string) *chord.Vnode {
+	meta := chord.Meta{"zone": []byte(zone), "rack": []byte(rack)}
+	return &chord.Vnode{Id: []byte(host), Host: host, Meta: meta.MarshalBinary()}
+}
+
+func testCandidates(vns ...[]*chord.Vnode) [][]*Location {
+	out := make([][]*Location, len(vns))
+	for i, l := range vns {
+		out[i] = make([]*Location, len(l))
+		for j, vn := range l {
+			out[i][j] = &Location{Vnode: vn, Priority: int32(i), Index: int32(j)}
+		}
+	}
+	return out
+}
+
+func TestSelectLocations(t *testing.T) {
+	var (
+		h1 = testVnode("h1", "a", "r1")
+		h2 = testVnode("h2", "a", "r1")
+		h3 = testVnode("h3", "b", "r1")
+		h4 = testVnode("h4", "c", "r1")
+		h5 = testVnode("h5", "a", "r2")
+	)
+
+	cands := testCandidates(
+		[]*chord.Vnode{h1, h2},
+		[]*chord.Vnode{h2, h3},
+		[]*chord.Vnode{h2, h4},
+	)
+
+	// Hosts only
+	locs := selectLocations(cands, nil, "")
+	if locs.String() != "[ h1 h2 h4 ]" {
+		t.Fatal("wrong hosts", locs)
+	}
+
+	// Zones first
+	locs = selectLocations(cands, []string{"zone"}, "")
+	if locs.String() != "[ h1 h3 h4 ]" {
+		t.Fatal("wrong hosts", locs)
+	}
+	if locs[1].Index != 1 || locs[1].Priority != 1 {
+		t.Fatal("wrong index/priority")
+	}
+
+	// Fallback to racks then hosts when there are too few zones
+	cands = testCandidates(
+		[]*chord.Vnode{h1},
+		[]*chord.Vnode{h2, h5},
+		[]*chord.Vnode{h1, h2, h3},
+	)
+	locs = selectLocations(cands, []string{"zone", "rack"}, "")
+	if locs.String() != "[ h1 h5 h3 ]" {
+		t.Fatal("wrong hosts", locs)
+	}
+
+	cands = testCandidates(
+		[]*chord.Vnode{h1},
+		[]*chord.Vnode{h2},
+		[]*chord.Vnode{h1, h2},
+	)
+	locs = selectLocations(cands, []string{"zone", "rack"}, "")
+	if locs[0] == nil || locs[1] == nil || locs[2] != nil {
+		t.Fatal("should not have enough hosts", locs)
+	}
+}
+
+func TestFailureDomainPath(t *testing.T) {
+	// Same rack name in different zones must be distinct
+	p1 := failureDomainPath(testVnode("h1", "a", "r1"), []string{"zone", "rack"})
+	p2 := failureDomainPath(testVnode("h2", "b", "r1"), []string{"zone", "rack"})
+	if p1[1] == p2[1] {
+		t.Fatal("racks should differ across zones")
+	}
+	if len(p1) != 3 || p1[2] != "/a/r1/h1" {
+		t.Fatal("wrong path", p1)
+	}
+}
+
+// testRingLookup returns a SuccessorLookup over a static sorted set of vnodes
+func testRingLookup(vns []*chord.Vnode, succs int) SuccessorLookup {
+	sort.Slice(vns, func(i, j int) bool { return bytes.Compare(vns[i].Id, vns[j].Id) < 0 })
+
+	return func(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+		i := sort.Search(len(vns), func(i int) bool { return bytes.Compare(vns[i].Id, hash) >= 0 })
+		out := make([]*chord.Vnode, 0, succs)
+		for j := 0; j < succs && j < len(vns); j++ {
+			out = append(out, vns[(i+j)%len(vns)])
+		}
+		return out, nil
+	}
+}
+
+func testRingVnodes(hosts ...string) []*chord.Vnode {
+	var vns []*chord.Vnode
+	for _, h := range hosts {
+		for i := 0; i < 4; i++ {
+			id := sha1.Sum([]byte{byte(i), ':', h[0], h[len(h)-1]})
+			vns = append(vns, &chord.Vnode{Id: id[:], Host: h})
+		}
+	}
+	return vns
+}
+
+func TestPlacementStrategies(t *testing.T) {
+	lookup := testRingLookup(testRingVnodes("h1", "h2", "h3", "h4"), 8)
+	hash := sha1.Sum([]byte("key"))
+
+	strategies := []PlacementStrategy{
+		NewEquidistantPlacement(),
+		NewSuccessorPlacement(),
+		NewRendezvousPlacement(sha1.New),
+	}
+
+	for _, st := range strategies {
+		locs, err := st.Place(context.Background(), lookup, hash[:], 3)
+		if err != nil {
+			t.Fatalf("%T %v", st, err)
+		}
+		if len(locs) != 3 {
+			t.Fatalf("%T should have 3 locations", st)
+		}
+		seen := map[string]bool{}
+		for i, l := range locs {
+			if seen[l.Host()] {
+				t.Fatalf("%T duplicate host %s", st, l.Host())
+			}
+			seen[l.Host()] = true
+			if l.Priority != int32(i) {
+				t.Fatalf("%T wrong priority", st)
+			}
+		}
+
+		// Deterministic
+		locs2, _ := st.Place(context.Background(), lookup, hash[:], 3)
+		if locs.String() != locs2.String() {
+			t.Fatalf("%T not deterministic", st)
+		}
+
+		if _, err = st.Place(context.Background(), lookup, hash[:], 5); err == nil {
+			t.Fatalf("%T should fail with not enough hosts", st)
+		}
+	}
+
+	// Successor placement is in successor order
+	vs, _ := lookup(context.Background(), hash[:])
+	locs, _ := NewSuccessorPlacement().Place(context.Background(), lookup, hash[:], 2)
+	if locs[0].Vnode != vs[0] || !equalBytes(locs[0].ID, hash[:]) || locs[0].Index != 0 {
+		t.Fatal("primary should be the first successor")
+	}
+
+	// Rendezvous index is the position in the successor list
+	locs, _ = NewRendezvousPlacement(sha1.New).Place(context.Background(), lookup, hash[:], 3)
+	for _, l := range locs {
+		if vs[l.Index] != l.Vnode {
+			t.Fatal("wrong index")
+		}
+	}
+}
+
+func TestSelectLocations_Weighted(t *testing.T) {
+	light := &chord.Vnode{Id: []byte("light"), Host: "light", Meta: chord.Meta{"weight": []byte("1")}.MarshalBinary()}
+	heavy := &chord.Vnode{Id: []byte("heavy"), Host: "heavy", Meta: chord.Meta{"weight": []byte("3")}.MarshalBinary()}
+
+	var c int
+	trials := 4000
+	for i := 0; i < trials; i++ {
+		id := sha1.Sum([]byte{byte(i), byte(i >> 8)})
+		cands := [][]*Location{{
+			&Location{ID: id[:], Vnode: light},
+			&Location{ID: id[:], Vnode: heavy, Index: 1},
+		}}
+		locs := selectLocations(cands, nil, "weight")
+		if locs[0].Host() == "heavy" {
+			c++
+		}
+	}
+
+	// Expect 3/4 of the replicas on the heavy node
+	if r := float64(c) / float64(trials); r < 0.7 || r > 0.8 {
+		t.Fatal("weight not proportional", r)
+	}
+
+	// Weighting only applies between equally ranked successors
+	cands := testCandidates([]*chord.Vnode{light}, []*chord.Vnode{light, heavy})
+	locs := selectLocations(cands, nil, "weight")
+	if locs[0].Host() != "light" || locs[1].Host() != "heavy" {
+		t.Fatal("wrong hosts", locs)
+	}
+}
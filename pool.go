@@ -0,0 +1,228 @@
+package hexaring
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+var errPoolShutdown = fmt.Errorf("transport is shutdown")
+
+type rpcOutConn struct {
+	host   string
+	conn   *grpc.ClientConn
+	client LookupRPCClient
+	merkle MerkleRPCClient
+
+	// Unix nanoseconds of the last use
+	used int64
+	// Number of open streams.  The connection is not reaped while streams are open
+	streams int32
+}
+
+func (out *rpcOutConn) touch() {
+	atomic.StoreInt64(&out.used, time.Now().UnixNano())
+}
+
+func (out *rpcOutConn) lastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&out.used))
+}
+
+// healthy returns false if the connection is failing or has been shutdown
+func (out *rpcOutConn) healthy() bool {
+	switch out.conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return false
+	}
+	return true
+}
+
+// openStream marks a stream as open on the connection.  The returned function cancels
+// the stream and marks it closed.  It is safe to call more than once.
+func (out *rpcOutConn) openStream(cancel context.CancelFunc) context.CancelFunc {
+	atomic.AddInt32(&out.streams, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			out.touch()
+			atomic.AddInt32(&out.streams, -1)
+		})
+	}
+}
+
+// PoolStats contains connection pool counters
+type PoolStats struct {
+	// Number of open connections
+	Open int
+	// Total dials and failed dials
+	Dials      uint64
+	DialErrors uint64
+	// Connections removed as they were failing or shutdown.  They are closed once idle
+	Evictions uint64
+	// Connections closed as they were idle
+	Reaped uint64
+}
+
+// dialCall is an in-progress dial shared by concurrent callers for the same host
+type dialCall struct {
+	done chan struct{}
+	out  *rpcOutConn
+	err  error
+}
+
+// connPool is a pool of client connections keyed by host.  Concurrent requests for a
+// host that is not connected share a single dial.
+type connPool struct {
+	dial    func(host string) (*grpc.ClientConn, error)
+	maxIdle time.Duration
+
+	mu      sync.Mutex
+	conns   map[string]*rpcOutConn
+	dialing map[string]*dialCall
+	// Removed connections that may still be in use.  They are closed by reap once idle
+	evicted  []*rpcOutConn
+	shutdown bool
+	stats    PoolStats
+}
+
+func newConnPool(maxIdle time.Duration, dial func(host string) (*grpc.ClientConn, error)) *connPool {
+	return &connPool{
+		dial:    dial,
+		maxIdle: maxIdle,
+		conns:   make(map[string]*rpcOutConn),
+		dialing: make(map[string]*dialCall),
+	}
+}
+
+// get returns a healthy connection to the host dialing if needed.  Unhealthy
+// connections are evicted and redialed.  Evicted connections are not closed as other
+// calls may still be using them.
+func (p *connPool) get(host string) (*rpcOutConn, error) {
+	p.mu.Lock()
+	if p.shutdown {
+		p.mu.Unlock()
+		return nil, errPoolShutdown
+	}
+
+	if out, ok := p.conns[host]; ok {
+		if out.healthy() {
+			p.mu.Unlock()
+			out.touch()
+			return out, nil
+		}
+		p.evict(host, out)
+	}
+
+	// Wait on a dial in progress
+	if call, ok := p.dialing[host]; ok {
+		p.mu.Unlock()
+		<-call.done
+		if call.err == nil {
+			call.out.touch()
+		}
+		return call.out, call.err
+	}
+
+	call := &dialCall{done: make(chan struct{})}
+	p.dialing[host] = call
+	p.stats.Dials++
+	p.mu.Unlock()
+
+	conn, err := p.dial(host)
+
+	p.mu.Lock()
+	delete(p.dialing, host)
+	if err != nil {
+		p.stats.DialErrors++
+	} else if p.shutdown {
+		conn.Close()
+		err = errPoolShutdown
+	} else {
+		call.out = &rpcOutConn{
+			host:   host,
+			conn:   conn,
+			client: NewLookupRPCClient(conn),
+			merkle: NewMerkleRPCClient(conn),
+		}
+		call.out.touch()
+		p.conns[host] = call.out
+	}
+	call.err = err
+	p.mu.Unlock()
+
+	close(call.done)
+
+	return call.out, call.err
+}
+
+// evict removes the connection of the host from the pool.  It is closed by reap once
+// idle.  The lock must be held.
+func (p *connPool) evict(host string, out *rpcOutConn) {
+	delete(p.conns, host)
+	p.evicted = append(p.evicted, out)
+	p.stats.Evictions++
+}
+
+// reap evicts unhealthy connections and closes those idle longer than the max idle time
+// without open streams, including evicted ones
+func (p *connPool) reap() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for host, out := range p.conns {
+		if !out.healthy() {
+			p.evict(host, out)
+		} else if p.idle(out) {
+			out.conn.Close()
+			delete(p.conns, host)
+			p.stats.Reaped++
+		}
+	}
+
+	evicted := p.evicted[:0]
+	for _, out := range p.evicted {
+		if p.idle(out) {
+			out.conn.Close()
+		} else {
+			evicted = append(evicted, out)
+		}
+	}
+	p.evicted = evicted
+}
+
+// idle returns true if the connection has no open streams and has not been used for the
+// max idle time
+func (p *connPool) idle(out *rpcOutConn) bool {
+	return atomic.LoadInt32(&out.streams) == 0 && time.Since(out.lastUsed()) > p.maxIdle
+}
+
+// close closes all connections.  No new connections can be made once closed.
+func (p *connPool) close() {
+	p.mu.Lock()
+	p.shutdown = true
+	for _, out := range p.conns {
+		out.conn.Close()
+	}
+	for _, out := range p.evicted {
+		out.conn.Close()
+	}
+	p.conns = map[string]*rpcOutConn{}
+	p.evicted = nil
+	p.mu.Unlock()
+}
+
+func (p *connPool) getStats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Open = len(p.conns)
+	return stats
+}
@@ -0,0 +1,160 @@
+package hexaring
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func testDialer(dials *int32, delay time.Duration) func(string) (*grpc.ClientConn, error) {
+	return func(host string) (*grpc.ClientConn, error) {
+		atomic.AddInt32(dials, 1)
+		<-time.After(delay)
+		return grpc.Dial(host, grpc.WithInsecure())
+	}
+}
+
+func TestConnPool_SingleDial(t *testing.T) {
+	var dials int32
+	p := newConnPool(time.Minute, testDialer(&dials, 50*time.Millisecond))
+	defer p.close()
+
+	var wg sync.WaitGroup
+	outs := make([]*rpcOutConn, 10)
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := p.get("127.0.0.1:47779")
+			if err != nil {
+				t.Error(err)
+			}
+			outs[i] = out
+		}(i)
+	}
+	wg.Wait()
+
+	if dials != 1 {
+		t.Fatal("should dial once", dials)
+	}
+	for _, out := range outs {
+		if out != outs[0] {
+			t.Fatal("should share the connection")
+		}
+	}
+
+	stats := p.getStats()
+	if stats.Open != 1 || stats.Dials != 1 {
+		t.Fatalf("wrong stats %+v", stats)
+	}
+}
+
+func TestConnPool_Reap(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:47780")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go server.Serve(ln)
+	defer server.Stop()
+
+	var dials int32
+	p := newConnPool(50*time.Millisecond, testDialer(&dials, 0))
+	defer p.close()
+
+	out, err := p.get("127.0.0.1:47780")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep using the connection past the max idle time
+	for i := 0; i < 4; i++ {
+		<-time.After(20 * time.Millisecond)
+		if _, err = p.get("127.0.0.1:47780"); err != nil {
+			t.Fatal(err)
+		}
+		p.reap()
+	}
+	if p.getStats().Open != 1 {
+		t.Fatal("used connection should not be reaped")
+	}
+
+	// Open streams keep the connection
+	release := out.openStream(func() {})
+	<-time.After(60 * time.Millisecond)
+	p.reap()
+	if p.getStats().Open != 1 {
+		t.Fatal("connection with streams should not be reaped")
+	}
+
+	release()
+	release()
+	if out.streams != 0 {
+		t.Fatal("stream should be released once")
+	}
+	<-time.After(60 * time.Millisecond)
+	p.reap()
+	if stats := p.getStats(); stats.Open != 0 || stats.Reaped != 1 {
+		t.Fatalf("idle connection should be reaped %+v", stats)
+	}
+
+	p.close()
+	if _, err = p.get("127.0.0.1:47780"); err != errPoolShutdown {
+		t.Fatal("should fail with shutdown", err)
+	}
+}
+
+func TestConnPool_Evict(t *testing.T) {
+	var dials int32
+	p := newConnPool(time.Minute, testDialer(&dials, 0))
+	defer p.close()
+
+	// Nothing listening so the connection fails
+	out, err := p.get("127.0.0.1:47779")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for s := out.conn.GetState(); s != connectivity.TransientFailure; s = out.conn.GetState() {
+		if !out.conn.WaitForStateChange(ctx, s) {
+			t.Fatal("connection should fail")
+		}
+	}
+
+	out2, err := p.get("127.0.0.1:47779")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out2 == out {
+		t.Fatal("failed connection should be replaced")
+	}
+	if out.conn.GetState() == connectivity.Shutdown {
+		t.Fatal("failed connection may still be in use")
+	}
+
+	stats := p.getStats()
+	if stats.Evictions != 1 || stats.Dials != 2 || stats.Open != 1 {
+		t.Fatalf("wrong stats %+v", stats)
+	}
+
+	// Closed once idle
+	p.maxIdle = 0
+	release := out.openStream(func() {})
+	p.reap()
+	if out.conn.GetState() == connectivity.Shutdown {
+		t.Fatal("failed connection with streams should not be closed")
+	}
+	release()
+	p.reap()
+	if out.conn.GetState() != connectivity.Shutdown || len(p.evicted) != 0 {
+		t.Fatal("idle failed connection should be closed")
+	}
+}
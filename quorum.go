@@ -0,0 +1,176 @@
+package hexaring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNoQuorum is returned when not enough replicas agree for the quorum to be reached
+var ErrNoQuorum = errors.New("quorum not reached")
+
+// ReplicaOp is an operation run against a single replica location.  The returned value
+// is compared byte-wise with those of the other replicas to count agreement, so it
+// should be a canonical encoding of the result e.g. a version or a digest.  The context
+// is cancelled once the outcome is decided.
+type ReplicaOp func(ctx context.Context, loc *Location) ([]byte, error)
+
+// ReplicaReply is the outcome of an operation on a replica
+type ReplicaReply struct {
+	Location *Location
+	Value    []byte
+	Err      error
+}
+
+// QuorumResult reports how each replica responded
+type QuorumResult struct {
+	// Value agreed upon by the quorum.  nil if the quorum was not reached
+	Value []byte
+	// Replicas that returned the agreed value
+	Agreed []*ReplicaReply
+	// Replicas that returned a different value
+	Disagreed []*ReplicaReply
+	// Replicas whose operation returned an error
+	Failed []*ReplicaReply
+	// Replicas that had not responded when the outcome was decided.  Their operations
+	// are cancelled.
+	Pending []*Location
+	// Replicas that had not responded when the context was done
+	TimedOut []*Location
+}
+
+// ExecuteQuorum runs the operation against each location concurrently and returns once
+// the required number of replicas return the same value.  It returns ErrNoQuorum as
+// soon as the quorum can no longer be reached and the context error if the context is
+// done first.  The result is returned in all cases.
+func ExecuteQuorum(ctx context.Context, locs LocationSet, required int, op ReplicaOp) (*QuorumResult, error) {
+	if required < 1 || required > len(locs) {
+		return nil, fmt.Errorf("invalid quorum %d for %d replicas", required, len(locs))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type indexedReply struct {
+		i int
+		*ReplicaReply
+	}
+
+	ch := make(chan indexedReply, len(locs))
+	for i, loc := range locs {
+		go func(i int, loc *Location) {
+			val, err := op(ctx, loc)
+			ch <- indexedReply{i: i, ReplicaReply: &ReplicaReply{Location: loc, Value: val, Err: err}}
+		}(i, loc)
+	}
+
+	var (
+		replies = make([]*ReplicaReply, len(locs))
+		counts  = make(map[string]int)
+		best    int
+	)
+
+	for pending := len(locs); pending > 0; {
+		select {
+		case rep := <-ch:
+			pending--
+			replies[rep.i] = rep.ReplicaReply
+			if rep.Err == nil {
+				k := string(rep.Value)
+				counts[k]++
+				if counts[k] >= required {
+					// Non-nil to distinguish an agreed empty value from no agreement
+					return newQuorumResult(replies, locs, []byte(k), false), nil
+				}
+				if counts[k] > best {
+					best = counts[k]
+				}
+			}
+
+			if best+pending < required {
+				return newQuorumResult(replies, locs, nil, false), ErrNoQuorum
+			}
+
+		case <-ctx.Done():
+			return newQuorumResult(replies, locs, nil, true), ctx.Err()
+		}
+	}
+
+	return newQuorumResult(replies, locs, nil, false), ErrNoQuorum
+}
+
+// newQuorumResult classifies the replies against the agreed value.  Missing replies are
+// reported as timed out or pending.
+func newQuorumResult(replies []*ReplicaReply, locs LocationSet, value []byte, timedOut bool) *QuorumResult {
+	res := &QuorumResult{Value: value}
+	for i, rep := range replies {
+		switch {
+		case rep == nil && timedOut:
+			res.TimedOut = append(res.TimedOut, locs[i])
+		case rep == nil:
+			res.Pending = append(res.Pending, locs[i])
+		case rep.Err != nil:
+			res.Failed = append(res.Failed, rep)
+		case value != nil && bytes.Equal(rep.Value, value):
+			res.Agreed = append(res.Agreed, rep)
+		default:
+			res.Disagreed = append(res.Disagreed, rep)
+		}
+	}
+	return res
+}
+
+// Quorum coordinates operations over the replicas of a key tolerating a given number of
+// faulty replicas.  A key is placed on 3f+1 replicas, a proposal requires 2f+1 matching
+// votes and a commit f+1 matching acknowledgements.
+type Quorum struct {
+	faulty int
+	lookup func(ctx context.Context, key []byte, n int) (LocationSet, error)
+}
+
+// NewQuorum instantiates a Quorum tolerating the given number of faulty replicas using
+// the ring to locate replicas.  It returns an error if faulty is negative.
+func NewQuorum(r *Ring, faulty int) (*Quorum, error) {
+	if faulty < 0 {
+		return nil, fmt.Errorf("faulty replicas must not be negative: %d", faulty)
+	}
+	return &Quorum{faulty: faulty, lookup: r.LookupReplicatedCtx}, nil
+}
+
+// Replicas returns the number of replicas a key is placed on
+func (q *Quorum) Replicas() int {
+	return replicasWithFault(q.faulty)
+}
+
+// Locations returns the replica locations of the key
+func (q *Quorum) Locations(ctx context.Context, key []byte) (LocationSet, error) {
+	return q.lookup(ctx, key, q.Replicas())
+}
+
+// Vote runs the operation on the replicas of the key and returns once 2f+1 replicas
+// return the same value
+func (q *Quorum) Vote(ctx context.Context, key []byte, op ReplicaOp) (*QuorumResult, error) {
+	return q.execute(ctx, key, votesWithFault(q.faulty), op)
+}
+
+// Commit runs the operation on the replicas of the key and returns once f+1 replicas
+// return the same value
+func (q *Quorum) Commit(ctx context.Context, key []byte, op ReplicaOp) (*QuorumResult, error) {
+	return q.execute(ctx, key, commitsWithFault(q.faulty), op)
+}
+
+// Majority runs the operation on the replicas of the key and returns once a majority of
+// replicas return the same value
+func (q *Quorum) Majority(ctx context.Context, key []byte, op ReplicaOp) (*QuorumResult, error) {
+	return q.execute(ctx, key, q.Replicas()/2+1, op)
+}
+
+func (q *Quorum) execute(ctx context.Context, key []byte, required int, op ReplicaOp) (*QuorumResult, error) {
+	locs, err := q.Locations(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return ExecuteQuorum(ctx, locs, required, op)
+}
@@ -0,0 +1,142 @@
+package hexaring
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// testReplicaOp returns the value for the host, an error or blocks until cancelled
+func testReplicaOp(values map[string]string) ReplicaOp {
+	return func(ctx context.Context, loc *Location) ([]byte, error) {
+		v, ok := values[loc.Vnode.Host]
+		switch {
+		case !ok:
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case v == "err":
+			return nil, fmt.Errorf("failed")
+		}
+		return []byte(v), nil
+	}
+}
+
+func TestExecuteQuorum(t *testing.T) {
+	locs := testLocationSet("h1", "h2", "h3", "h4")
+
+	res, err := ExecuteQuorum(context.Background(), locs, 3, testReplicaOp(map[string]string{
+		"h1": "a", "h2": "a", "h3": "b", "h4": "a",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "a" {
+		t.Fatal("wrong value", string(res.Value))
+	}
+	if len(res.Agreed) != 3 {
+		t.Fatal("should have 3 agreed", len(res.Agreed))
+	}
+	if len(res.Agreed)+len(res.Disagreed)+len(res.Pending) != 4 {
+		t.Fatal("all replicas should be reported", res)
+	}
+
+	// Quorum not reachable
+	res, err = ExecuteQuorum(context.Background(), locs, 3, testReplicaOp(map[string]string{
+		"h1": "a", "h2": "b", "h3": "err", "h4": "a",
+	}))
+	if err != ErrNoQuorum {
+		t.Fatal("should fail with no quorum", err)
+	}
+	if res.Value != nil || len(res.Failed) != 1 || len(res.Disagreed)+len(res.Pending) != 3 {
+		t.Fatal("wrong result", res)
+	}
+
+	// Empty values agree
+	res, err = ExecuteQuorum(context.Background(), locs, 2, testReplicaOp(map[string]string{
+		"h1": "", "h2": "", "h3": "", "h4": "",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value == nil || len(res.Agreed) < 2 || len(res.Disagreed) != 0 {
+		t.Fatal("empty values should agree", res)
+	}
+
+	// Slow replicas
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err = ExecuteQuorum(ctx, locs, 3, testReplicaOp(map[string]string{"h1": "a", "h2": "a"}))
+	if err != context.DeadlineExceeded {
+		t.Fatal("should time out", err)
+	}
+	if len(res.TimedOut) != 2 || len(res.Disagreed) != 2 {
+		t.Fatal("wrong result", res)
+	}
+
+	if _, err = ExecuteQuorum(context.Background(), locs, 5, testReplicaOp(nil)); err == nil {
+		t.Fatal("should fail with quorum larger than replicas")
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	q := &Quorum{
+		faulty: 1,
+		lookup: func(ctx context.Context, key []byte, n int) (LocationSet, error) {
+			hosts := make([]string, n)
+			for i := range hosts {
+				hosts[i] = fmt.Sprintf("h%d", i+1)
+			}
+			return testLocationSet(hosts...), nil
+		},
+	}
+	if q.Replicas() != 4 {
+		t.Fatal("should have 4 replicas", q.Replicas())
+	}
+
+	// One faulty replica
+	op := testReplicaOp(map[string]string{"h1": "a", "h2": "a", "h3": "a", "h4": "b"})
+
+	res, err := q.Vote(context.Background(), testkey, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Agreed) != 3 {
+		t.Fatal("vote should need 3", len(res.Agreed))
+	}
+
+	if res, err = q.Commit(context.Background(), testkey, op); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Agreed) < 2 {
+		t.Fatal("commit should need 2", len(res.Agreed))
+	}
+
+	if res, err = q.Majority(context.Background(), testkey, op); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Agreed) != 3 {
+		t.Fatal("majority should need 3", len(res.Agreed))
+	}
+
+	// Two faulty replicas exceed the tolerance
+	op = testReplicaOp(map[string]string{"h1": "a", "h2": "a", "h3": "b", "h4": "c"})
+	if _, err = q.Vote(context.Background(), testkey, op); err != ErrNoQuorum {
+		t.Fatal("vote should fail", err)
+	}
+}
+
+func TestNewQuorum(t *testing.T) {
+	r := New(fastConf("127.0.0.1:47786"), NewInMemPeerStore())
+	if _, err := NewQuorum(r, -1); err == nil {
+		t.Fatal("should reject negative faulty replicas")
+	}
+	q, err := NewQuorum(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Replicas() != 1 {
+		t.Fatal("should have 1 replica", q.Replicas())
+	}
+}
@@ -0,0 +1,347 @@
+package hexaring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrVersionConflict is returned when replicas hold concurrent versions and no resolver
+// is configured
+var ErrVersionConflict = errors.New("concurrent versions")
+
+// VersionOrder is the order of two versions
+type VersionOrder int
+
+const (
+	// VersionEqual versions are the same
+	VersionEqual VersionOrder = iota
+	// VersionNewer is returned when the first version supersedes the second
+	VersionNewer
+	// VersionOlder is returned when the second version supersedes the first
+	VersionOlder
+	// VersionConcurrent versions were updated independently
+	VersionConcurrent
+)
+
+// VersionCompare compares the versions of two replica values
+type VersionCompare func(a, b []byte) VersionOrder
+
+// VersionResolver merges concurrent replica values into a single value
+type VersionResolver func(values [][]byte) ([]byte, error)
+
+// RepairWriter writes the winning value to a stale replica.  It is supplied by the
+// storage layer.
+type RepairWriter func(ctx context.Context, loc *Location, value []byte) error
+
+// ReadRepairConfig contains the read repair options
+type ReadRepairConfig struct {
+	// Compares replica values.  Required
+	Compare VersionCompare
+	// Writes the winner to stale replicas.  Required
+	Write RepairWriter
+	// Optional resolver of concurrent values.  Reads with concurrent values fail with
+	// ErrVersionConflict if not set
+	Resolve VersionResolver
+	// Max time allowed for a repair write
+	RepairTimeout time.Duration
+}
+
+// ReadRepairStats contains read repair counters
+type ReadRepairStats struct {
+	// Reads reconciled
+	Reads uint64
+	// Reads where at least one replica was stale
+	Divergent uint64
+	// Reads with concurrent values
+	Conflicts uint64
+	// Repair writes issued and failed
+	Repairs      uint64
+	RepairErrors uint64
+}
+
+// ReadRepairResult is the outcome of a reconciled read
+type ReadRepairResult struct {
+	// Winning value
+	Value []byte
+	// Replicas holding the winning value
+	Current []*Location
+	// Replicas being repaired with the winning value
+	Stale []*Location
+	// Replicas whose read failed.  These are not repaired
+	Failed []*ReplicaReply
+}
+
+// ReadRepair reconciles the values read from replicas, returning the winner and pushing
+// it to stale replicas in the background
+type ReadRepair struct {
+	conf *ReadRepairConfig
+
+	reads        uint64
+	divergent    uint64
+	conflicts    uint64
+	repairs      uint64
+	repairErrors uint64
+
+	wg sync.WaitGroup
+}
+
+// NewReadRepair instantiates a ReadRepair
+func NewReadRepair(conf *ReadRepairConfig) *ReadRepair {
+	return &ReadRepair{conf: conf}
+}
+
+// Read reads from every location concurrently and reconciles the replies.  It waits on
+// all replicas or until the context is done, unanswered replicas being treated as
+// failed.
+func (rr *ReadRepair) Read(ctx context.Context, locs LocationSet, read ReplicaOp) (*ReadRepairResult, error) {
+	ch := make(chan *ReplicaReply, len(locs))
+	for _, loc := range locs {
+		go func(loc *Location) {
+			val, err := read(ctx, loc)
+			ch <- &ReplicaReply{Location: loc, Value: val, Err: err}
+		}(loc)
+	}
+
+	replies := make([]*ReplicaReply, 0, len(locs))
+	answered := make(map[*Location]bool, len(locs))
+	for range locs {
+		select {
+		case rep := <-ch:
+			replies = append(replies, rep)
+			answered[rep.Location] = true
+		case <-ctx.Done():
+			for _, loc := range locs {
+				if !answered[loc] {
+					replies = append(replies, &ReplicaReply{Location: loc, Err: ctx.Err()})
+				}
+			}
+			return rr.Reconcile(replies)
+		}
+	}
+
+	return rr.Reconcile(replies)
+}
+
+// Reconcile picks the winner among the replies e.g. the result of a quorum or
+// consistency level read, and asynchronously writes it to the replicas holding another
+// version.  The winner is the value superseding all others.  Concurrent values are
+// merged with the resolver.
+func (rr *ReadRepair) Reconcile(replies []*ReplicaReply) (*ReadRepairResult, error) {
+	res := &ReadRepairResult{}
+	var ok []*ReplicaReply
+	for _, rep := range replies {
+		if rep.Err != nil {
+			res.Failed = append(res.Failed, rep)
+		} else {
+			ok = append(ok, rep)
+		}
+	}
+	if len(ok) == 0 {
+		return res, fmt.Errorf("no replica replied")
+	}
+	atomic.AddUint64(&rr.reads, 1)
+
+	// Values not superseded by any other
+	var latest [][]byte
+	for _, rep := range ok {
+		dominated := false
+		for i := 0; i < len(latest); i++ {
+			switch rr.conf.Compare(rep.Value, latest[i]) {
+			case VersionEqual, VersionOlder:
+				dominated = true
+			case VersionNewer:
+				latest = append(latest[:i], latest[i+1:]...)
+				i--
+			}
+			if dominated {
+				break
+			}
+		}
+		if !dominated {
+			latest = append(latest, rep.Value)
+		}
+	}
+
+	res.Value = latest[0]
+	if len(latest) > 1 {
+		atomic.AddUint64(&rr.conflicts, 1)
+		if rr.conf.Resolve == nil {
+			return res, ErrVersionConflict
+		}
+		var err error
+		if res.Value, err = rr.conf.Resolve(latest); err != nil {
+			return res, err
+		}
+	}
+
+	for _, rep := range ok {
+		if rr.conf.Compare(rep.Value, res.Value) == VersionEqual {
+			res.Current = append(res.Current, rep.Location)
+		} else {
+			res.Stale = append(res.Stale, rep.Location)
+		}
+	}
+
+	if len(res.Stale) > 0 {
+		atomic.AddUint64(&rr.divergent, 1)
+		for _, loc := range res.Stale {
+			rr.repair(loc, res.Value)
+		}
+	}
+
+	return res, nil
+}
+
+// repair writes the value to the location in the background
+func (rr *ReadRepair) repair(loc *Location, value []byte) {
+	atomic.AddUint64(&rr.repairs, 1)
+	rr.wg.Add(1)
+
+	go func() {
+		defer rr.wg.Done()
+
+		ctx := context.Background()
+		if rr.conf.RepairTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rr.conf.RepairTimeout)
+			defer cancel()
+		}
+
+		if err := rr.conf.Write(ctx, loc, value); err != nil {
+			atomic.AddUint64(&rr.repairErrors, 1)
+		}
+	}()
+}
+
+// Wait blocks until in-flight repairs complete
+func (rr *ReadRepair) Wait() {
+	rr.wg.Wait()
+}
+
+// Stats returns the read repair counters
+func (rr *ReadRepair) Stats() ReadRepairStats {
+	return ReadRepairStats{
+		Reads:        atomic.LoadUint64(&rr.reads),
+		Divergent:    atomic.LoadUint64(&rr.divergent),
+		Conflicts:    atomic.LoadUint64(&rr.conflicts),
+		Repairs:      atomic.LoadUint64(&rr.repairs),
+		RepairErrors: atomic.LoadUint64(&rr.repairErrors),
+	}
+}
+
+// VectorClock is a version made of a counter per writer
+type VectorClock map[string]uint64
+
+// Increment increments the counter of the writer
+func (vc VectorClock) Increment(id string) {
+	vc[id]++
+}
+
+// Merge returns a clock with the max counter of each writer in both clocks
+func (vc VectorClock) Merge(other VectorClock) VectorClock {
+	out := make(VectorClock, len(vc))
+	for id, c := range vc {
+		out[id] = c
+	}
+	for id, c := range other {
+		if c > out[id] {
+			out[id] = c
+		}
+	}
+	return out
+}
+
+// Compare returns the order of the clock relative to the other one
+func (vc VectorClock) Compare(other VectorClock) VersionOrder {
+	var newer, older bool
+	for id, c := range vc {
+		if c > other[id] {
+			newer = true
+		} else if c < other[id] {
+			older = true
+		}
+	}
+	for id, c := range other {
+		if _, ok := vc[id]; !ok && c > 0 {
+			older = true
+		}
+	}
+
+	switch {
+	case newer && older:
+		return VersionConcurrent
+	case newer:
+		return VersionNewer
+	case older:
+		return VersionOlder
+	}
+	return VersionEqual
+}
+
+// MarshalBinary encodes the clock ordered by writer id so equal clocks have the same
+// encoding
+func (vc VectorClock) MarshalBinary() ([]byte, error) {
+	ids := make([]string, 0, len(vc))
+	for id := range vc {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	var out []byte
+	for _, id := range ids {
+		n := binary.PutUvarint(buf, uint64(len(id)))
+		out = append(out, buf[:n]...)
+		out = append(out, id...)
+		n = binary.PutUvarint(buf, vc[id])
+		out = append(out, buf[:n]...)
+	}
+	return out, nil
+}
+
+// UnmarshalBinary decodes a clock encoded with MarshalBinary
+func (vc *VectorClock) UnmarshalBinary(b []byte) error {
+	out := VectorClock{}
+	for len(b) > 0 {
+		l, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < l {
+			return fmt.Errorf("invalid vector clock")
+		}
+		id := string(b[n : n+int(l)])
+		b = b[n+int(l):]
+
+		c, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("invalid vector clock")
+		}
+		out[id] = c
+		b = b[n:]
+	}
+	*vc = out
+	return nil
+}
+
+// VectorClockCompare returns a VersionCompare of the vector clocks extracted from
+// values.  A value whose clock cannot be extracted is older than any valid one.
+func VectorClockCompare(extract func(value []byte) (VectorClock, error)) VersionCompare {
+	return func(a, b []byte) VersionOrder {
+		va, erra := extract(a)
+		vb, errb := extract(b)
+		switch {
+		case erra != nil && errb != nil:
+			return VersionEqual
+		case erra != nil:
+			return VersionOlder
+		case errb != nil:
+			return VersionNewer
+		}
+		return va.Compare(vb)
+	}
+}
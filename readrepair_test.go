@@ -0,0 +1,150 @@
+package hexaring
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func testClockValue(t *testing.T, vc VectorClock) []byte {
+	b, err := vc.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testExtractClock(value []byte) (VectorClock, error) {
+	var vc VectorClock
+	err := vc.UnmarshalBinary(value)
+	return vc, err
+}
+
+func TestVectorClock(t *testing.T) {
+	a := VectorClock{}
+	a.Increment("n1")
+	b := a.Merge(nil)
+	b.Increment("n2")
+
+	if a.Compare(b) != VersionOlder || b.Compare(a) != VersionNewer {
+		t.Fatal("b should supersede a")
+	}
+	if a.Compare(a.Merge(VectorClock{})) != VersionEqual {
+		t.Fatal("should be equal")
+	}
+
+	c := a.Merge(nil)
+	c.Increment("n3")
+	if b.Compare(c) != VersionConcurrent {
+		t.Fatal("should be concurrent")
+	}
+	m := b.Merge(c)
+	if m.Compare(b) != VersionNewer || m.Compare(c) != VersionNewer {
+		t.Fatal("merge should supersede both")
+	}
+
+	// Encoding is deterministic and round trips
+	enc := testClockValue(t, m)
+	if !bytes.Equal(enc, testClockValue(t, m.Merge(nil))) {
+		t.Fatal("encoding should be deterministic")
+	}
+	dec, err := testExtractClock(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Compare(m) != VersionEqual {
+		t.Fatal("should round trip", dec, m)
+	}
+	if _, err = testExtractClock([]byte{5, 'a'}); err == nil {
+		t.Fatal("should fail to decode")
+	}
+}
+
+type testRepairWriter struct {
+	mu     sync.Mutex
+	writes map[string][]byte
+}
+
+func (w *testRepairWriter) write(ctx context.Context, loc *Location, value []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if loc.Vnode.Host == "bad" {
+		return fmt.Errorf("write failed")
+	}
+	w.writes[loc.Vnode.Host] = value
+	return nil
+}
+
+func TestReadRepair(t *testing.T) {
+	w := &testRepairWriter{writes: map[string][]byte{}}
+	rr := NewReadRepair(&ReadRepairConfig{
+		Compare: VectorClockCompare(testExtractClock),
+		Write:   w.write,
+	})
+
+	v1 := VectorClock{"n1": 1}
+	v2 := v1.Merge(VectorClock{"n2": 1})
+	values := map[string][]byte{
+		"h1":  testClockValue(t, v2),
+		"h2":  testClockValue(t, v1),
+		"h3":  testClockValue(t, v2),
+		"bad": testClockValue(t, v1),
+	}
+	read := func(ctx context.Context, loc *Location) ([]byte, error) {
+		if v, ok := values[loc.Vnode.Host]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+
+	res, err := rr.Read(context.Background(), testLocationSet("h1", "h2", "h3", "bad", "h5"), read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Wait()
+
+	if !bytes.Equal(res.Value, values["h1"]) {
+		t.Fatal("wrong winner")
+	}
+	if len(res.Current) != 2 || len(res.Stale) != 2 || len(res.Failed) != 1 {
+		t.Fatal("wrong result", res)
+	}
+	if !bytes.Equal(w.writes["h2"], res.Value) || len(w.writes) != 1 {
+		t.Fatal("h2 should be repaired", w.writes)
+	}
+
+	stats := rr.Stats()
+	if stats.Reads != 1 || stats.Divergent != 1 || stats.Repairs != 2 || stats.RepairErrors != 1 {
+		t.Fatalf("wrong stats %+v", stats)
+	}
+
+	// Concurrent versions
+	v3 := v1.Merge(VectorClock{"n3": 1})
+	values["h2"] = testClockValue(t, v3)
+	if _, err = rr.Read(context.Background(), testLocationSet("h1", "h2"), read); err != ErrVersionConflict {
+		t.Fatal("should conflict", err)
+	}
+
+	rr.conf.Resolve = func(vals [][]byte) ([]byte, error) {
+		out := VectorClock{}
+		for _, v := range vals {
+			vc, _ := testExtractClock(v)
+			out = out.Merge(vc)
+		}
+		return out.MarshalBinary()
+	}
+	w.writes = map[string][]byte{}
+	if res, err = rr.Read(context.Background(), testLocationSet("h1", "h2", "h3"), read); err != nil {
+		t.Fatal(err)
+	}
+	rr.Wait()
+	if len(res.Stale) != 3 || len(w.writes) != 3 {
+		t.Fatal("all replicas should get the merged value", res.Stale, w.writes)
+	}
+	if rr.Stats().Conflicts != 2 {
+		t.Fatal("should count conflicts", rr.Stats().Conflicts)
+	}
+}
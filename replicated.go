@@ -0,0 +1,359 @@
+package hexaring
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// ConsistencyLevel is the number of replicas an operation must succeed on
+type ConsistencyLevel int
+
+const (
+	// ConsistencyOne requires a single replica
+	ConsistencyOne ConsistencyLevel = iota
+	// ConsistencyQuorum requires a majority of replicas
+	ConsistencyQuorum
+	// ConsistencyAll requires every replica
+	ConsistencyAll
+	// ConsistencyLocalZoneQuorum requires a majority of the replicas in the local zone
+	ConsistencyLocalZoneQuorum
+)
+
+var consistencyNames = []string{"ONE", "QUORUM", "ALL", "LOCAL_ZONE_QUORUM"}
+
+func (cl ConsistencyLevel) String() string {
+	if cl < 0 || int(cl) >= len(consistencyNames) {
+		return fmt.Sprintf("ConsistencyLevel(%d)", cl)
+	}
+	return consistencyNames[cl]
+}
+
+// ParseConsistencyLevel returns the level for a name e.g. QUORUM.  It is case
+// insensitive.
+func ParseConsistencyLevel(s string) (ConsistencyLevel, error) {
+	for i, name := range consistencyNames {
+		if strings.EqualFold(s, name) {
+			return ConsistencyLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown consistency level: %q", s)
+}
+
+// ReplicatedConfig contains the options of a Replicated executor
+type ReplicatedConfig struct {
+	// Number of replicas looked up per key.  Non-positive values default to 3
+	Replicas int
+	// Meta key holding the zone label of a node
+	ZoneKey string
+	// Zone of this node.  Defaults to the zone label in the ring meta
+	LocalZone string
+	// Time to wait on a replica before speculatively trying the next successor of its
+	// location.  Zero disables speculative retries.  Failed replicas are always retried
+	// on the next successor.
+	SpeculativeDelay time.Duration
+}
+
+// DefaultReplicatedConfig returns a sane config
+func DefaultReplicatedConfig() *ReplicatedConfig {
+	return &ReplicatedConfig{
+		Replicas:         3,
+		ZoneKey:          "zone",
+		SpeculativeDelay: 100 * time.Millisecond,
+	}
+}
+
+// ReplicatedResult reports the outcome of an operation on each replica location
+type ReplicatedResult struct {
+	Level ConsistencyLevel
+	// Successful reply per location in completion order.  The location vnode is the one
+	// that served the reply, a successor for a retried location.
+	Succeeded []*ReplicaReply
+	// Last failure of locations where every attempt failed
+	Failed []*ReplicaReply
+	// Locations without an outcome when the call returned.  Their operations are
+	// cancelled.
+	Pending []*Location
+	// Number of speculative attempts on successors
+	Speculative int
+}
+
+// Replicated executes operations over the replica locations of a key and resolves once
+// the requested consistency level is met
+type Replicated struct {
+	conf      *ReplicatedConfig
+	localZone string
+
+	lookup func(ctx context.Context, key []byte, n int) (LocationSet, error)
+	scour  func(ctx context.Context, locID []byte, cb func(*chord.Vnode) error) (int, error)
+}
+
+// NewReplicated instantiates a Replicated executor using the ring to locate replicas and
+// their successors.  A nil config uses the default config.
+func NewReplicated(r *Ring, conf *ReplicatedConfig) *Replicated {
+	// Copied so defaults are not written to the caller's config
+	c := *DefaultReplicatedConfig()
+	if conf != nil {
+		c = *conf
+	}
+	if c.Replicas <= 0 {
+		c.Replicas = DefaultReplicatedConfig().Replicas
+	}
+
+	rp := &Replicated{
+		conf:      &c,
+		localZone: c.LocalZone,
+		lookup:    r.LookupReplicatedCtx,
+		scour:     r.ScourReplicaCtx,
+	}
+	if rp.localZone == "" && r.conf.Config != nil {
+		rp.localZone = string(r.conf.Meta[c.ZoneKey])
+	}
+	return rp
+}
+
+// Execute runs the operation against the replica locations of the key
+func (rp *Replicated) Execute(ctx context.Context, key []byte, level ConsistencyLevel, op ReplicaOp) (*ReplicatedResult, error) {
+	locs, err := rp.lookup(ctx, key, rp.conf.Replicas)
+	if err != nil {
+		return nil, err
+	}
+	return rp.ExecuteLocations(ctx, locs, level, op)
+}
+
+// ExecuteLocations runs the operation against each location concurrently and returns
+// once the consistency level is met.  A location whose replica fails, or does not
+// respond within the speculative delay, is tried on the next successor not already in
+// the set or already claimed by another location, so each counted reply is from a
+// distinct host.  It returns an error as soon as the level can no longer be met or the
+// context is done.  The result is returned in all cases.
+func (rp *Replicated) ExecuteLocations(ctx context.Context, locs LocationSet, level ConsistencyLevel, op ReplicaOp) (*ReplicatedResult, error) {
+	required, local, err := rp.required(locs, level)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		speculative int32
+		claims      = newHostClaims(locs)
+	)
+	type slotReply struct {
+		i int
+		*ReplicaReply
+	}
+
+	ch := make(chan slotReply, len(locs))
+	for i := range locs {
+		go func(i int) {
+			rep := rp.runLocation(ctx, claims, locs[i], op, &speculative)
+			ch <- slotReply{i: i, ReplicaReply: rep}
+		}(i)
+	}
+
+	var (
+		res      = &ReplicatedResult{Level: level}
+		done     = make([]bool, len(locs))
+		met      int
+		metHosts = make(map[string]bool, len(locs))
+	)
+	result := func() *ReplicatedResult {
+		for i, ok := range done {
+			if !ok {
+				res.Pending = append(res.Pending, locs[i])
+			}
+		}
+		res.Speculative = int(atomic.LoadInt32(&speculative))
+		return res
+	}
+
+	for pending := len(locs); pending > 0; {
+		select {
+		case rep := <-ch:
+			pending--
+			done[rep.i] = true
+
+			if rep.Err != nil {
+				res.Failed = append(res.Failed, rep.ReplicaReply)
+			} else {
+				res.Succeeded = append(res.Succeeded, rep.ReplicaReply)
+				host := rep.Location.Vnode.Host
+				if (!local || rp.inLocalZone(rep.Location.Vnode)) && !metHosts[host] {
+					metHosts[host] = true
+					met++
+				}
+			}
+
+			if met >= required {
+				return result(), nil
+			}
+			if met+pending < required {
+				return result(), fmt.Errorf("consistency level %s not met: %d of %d replicas", level, met, required)
+			}
+
+		case <-ctx.Done():
+			return result(), ctx.Err()
+		}
+	}
+
+	return result(), fmt.Errorf("consistency level %s not met: %d of %d replicas", level, met, required)
+}
+
+// required returns the number of successful locations the level requires and whether
+// only local zone replicas count
+func (rp *Replicated) required(locs LocationSet, level ConsistencyLevel) (int, bool, error) {
+	if len(locs) == 0 {
+		return 0, false, fmt.Errorf("no locations")
+	}
+
+	switch level {
+	case ConsistencyOne:
+		return 1, false, nil
+	case ConsistencyQuorum:
+		return len(locs)/2 + 1, false, nil
+	case ConsistencyAll:
+		return len(locs), false, nil
+	case ConsistencyLocalZoneQuorum:
+		var n int
+		for _, loc := range locs {
+			if rp.inLocalZone(loc.Vnode) {
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, true, fmt.Errorf("no replicas in local zone: %q", rp.localZone)
+		}
+		return n/2 + 1, true, nil
+	}
+	return 0, false, fmt.Errorf("unknown consistency level: %s", level)
+}
+
+func (rp *Replicated) inLocalZone(vn *chord.Vnode) bool {
+	return rp.localZone != "" && string(vnodeMeta(vn)[rp.conf.ZoneKey]) == rp.localZone
+}
+
+// hostClaims is the set of hosts used by the locations of an execution.  A successor is
+// only tried by the location that claims its host first.
+type hostClaims struct {
+	mu    sync.Mutex
+	hosts map[string]bool
+}
+
+// newHostClaims returns the claims with the hosts of the location set already claimed
+func newHostClaims(locs LocationSet) *hostClaims {
+	hc := &hostClaims{hosts: make(map[string]bool, len(locs))}
+	for _, l := range locs {
+		hc.hosts[l.Vnode.Host] = true
+	}
+	return hc
+}
+
+// claim returns true if the host was not already claimed
+func (hc *hostClaims) claim(host string) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.hosts[host] {
+		return false
+	}
+	hc.hosts[host] = true
+	return true
+}
+
+// runLocation runs the operation for a location returning the first successful reply
+// or the last failure.  Attempts move to the next successor of the location on failure
+// and on each speculative delay without a reply.  Successors whose host is already
+// claimed are skipped.
+func (rp *Replicated) runLocation(ctx context.Context, claims *hostClaims, loc *Location, op ReplicaOp, speculative *int32) *ReplicaReply {
+	done := make(chan struct{})
+	defer close(done)
+
+	replies := make(chan *ReplicaReply, 1)
+	inflight := 0
+	launch := func(l *Location) {
+		inflight++
+		go func() {
+			val, err := op(ctx, l)
+			select {
+			case replies <- &ReplicaReply{Location: l, Value: val, Err: err}:
+			case <-done:
+			}
+		}()
+	}
+
+	var (
+		successors []*chord.Vnode
+		fetched    bool
+		fallbacks  int32
+		// Reported with the last failure if no fallback succeeds
+		scourErr error
+	)
+	next := func() *Location {
+		if !fetched {
+			fetched = true
+			_, scourErr = rp.scour(ctx, loc.ID, func(vn *chord.Vnode) error {
+				successors = append(successors, vn)
+				return nil
+			})
+		}
+		for len(successors) > 0 {
+			vn := successors[0]
+			successors = successors[1:]
+			if !claims.claim(vn.Host) {
+				continue
+			}
+			fallbacks++
+			return &Location{ID: loc.ID, Priority: loc.Priority, Index: fallbacks, Vnode: vn}
+		}
+		return nil
+	}
+
+	var timer <-chan time.Time
+	if rp.conf.SpeculativeDelay > 0 {
+		timer = time.After(rp.conf.SpeculativeDelay)
+	}
+
+	launch(loc)
+	var last *ReplicaReply
+	for {
+		select {
+		case rep := <-replies:
+			inflight--
+			if rep.Err == nil {
+				return rep
+			}
+			last = rep
+			if l := next(); l != nil {
+				launch(l)
+			} else if inflight == 0 {
+				if scourErr != nil {
+					last.Err = fmt.Errorf("%w (successor lookup failed: %v)", last.Err, scourErr)
+				}
+				return last
+			}
+
+		case <-timer:
+			timer = nil
+			if l := next(); l != nil {
+				atomic.AddInt32(speculative, 1)
+				launch(l)
+				timer = time.After(rp.conf.SpeculativeDelay)
+			}
+
+		case <-ctx.Done():
+			if last == nil {
+				last = &ReplicaReply{Location: loc}
+			}
+			last.Err = ctx.Err()
+			return last
+		}
+	}
+}
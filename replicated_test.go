@@ -0,0 +1,225 @@
+package hexaring
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// testReplicated returns an executor over the given vnodes.  Each location has the
+// following vnodes as successors.
+func testReplicated(conf *ReplicatedConfig, vns ...*chord.Vnode) *Replicated {
+	return &Replicated{
+		conf:      conf,
+		localZone: conf.LocalZone,
+		lookup: func(ctx context.Context, key []byte, n int) (LocationSet, error) {
+			locs := make(LocationSet, n)
+			for i := range locs {
+				locs[i] = &Location{ID: vns[i].Id, Priority: int32(i), Vnode: vns[i]}
+			}
+			return locs, nil
+		},
+		scour: func(ctx context.Context, locID []byte, cb func(*chord.Vnode) error) (int, error) {
+			for i, vn := range vns {
+				if string(vn.Id) == string(locID) {
+					for _, s := range vns[i:] {
+						cb(s)
+					}
+					return len(vns) - i, nil
+				}
+			}
+			return 0, nil
+		},
+	}
+}
+
+// testVnodeOp succeeds on hosts with a zero delay, sleeps before succeeding on those
+// with a positive one and fails on the others
+func testVnodeOp(delays map[string]time.Duration) ReplicaOp {
+	return func(ctx context.Context, loc *Location) ([]byte, error) {
+		d, ok := delays[loc.Vnode.Host]
+		if !ok || d < 0 {
+			return nil, fmt.Errorf("failed")
+		}
+		select {
+		case <-time.After(d):
+			return []byte(loc.Vnode.Host), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestParseConsistencyLevel(t *testing.T) {
+	for _, cl := range []ConsistencyLevel{ConsistencyOne, ConsistencyQuorum, ConsistencyAll, ConsistencyLocalZoneQuorum} {
+		p, err := ParseConsistencyLevel(cl.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != cl {
+			t.Fatal("wrong level", p, cl)
+		}
+	}
+	if _, err := ParseConsistencyLevel("local_zone_quorum"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseConsistencyLevel("TWO"); err == nil {
+		t.Fatal("should fail")
+	}
+}
+
+func TestReplicated_Levels(t *testing.T) {
+	conf := &ReplicatedConfig{Replicas: 3, ZoneKey: "zone", LocalZone: "z1"}
+	rp := testReplicated(conf,
+		testVnode("h1", "z1", "r1"),
+		testVnode("h2", "z1", "r2"),
+		testVnode("h3", "z2", "r1"),
+	)
+
+	// Only h1 succeeds and there are no successors outside the set
+	op := testVnodeOp(map[string]time.Duration{"h1": 0, "h2": time.Second, "h3": -1})
+
+	res, err := rp.Execute(context.Background(), testkey, ConsistencyOne, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Succeeded) != 1 || res.Succeeded[0].Location.Vnode.Host != "h1" {
+		t.Fatal("h1 should succeed", res)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err = rp.Execute(ctx, testkey, ConsistencyQuorum, op)
+	if err != context.DeadlineExceeded {
+		t.Fatal("quorum should time out", err)
+	}
+	if len(res.Succeeded) != 1 || len(res.Failed) != 1 || len(res.Pending) != 1 {
+		t.Fatal("wrong result", res)
+	}
+
+	if _, err = rp.Execute(context.Background(), testkey, ConsistencyAll, op); err == nil {
+		t.Fatal("all should fail")
+	}
+
+	// Local zone quorum needs both z1 replicas
+	op = testVnodeOp(map[string]time.Duration{"h1": 0, "h2": 0, "h3": -1})
+	if _, err = rp.Execute(context.Background(), testkey, ConsistencyLocalZoneQuorum, op); err != nil {
+		t.Fatal(err)
+	}
+	op = testVnodeOp(map[string]time.Duration{"h1": 0, "h2": -1, "h3": 0})
+	if _, err = rp.Execute(context.Background(), testkey, ConsistencyLocalZoneQuorum, op); err == nil {
+		t.Fatal("local zone quorum should fail")
+	}
+
+	rp.localZone = "z3"
+	if _, err = rp.Execute(context.Background(), testkey, ConsistencyLocalZoneQuorum, op); err == nil {
+		t.Fatal("should fail without local replicas")
+	}
+}
+
+func TestReplicated_Speculative(t *testing.T) {
+	conf := &ReplicatedConfig{Replicas: 2, ZoneKey: "zone", SpeculativeDelay: 20 * time.Millisecond}
+	rp := testReplicated(conf,
+		testVnode("h1", "z1", "r1"),
+		testVnode("h2", "z1", "r1"),
+		testVnode("h3", "z1", "r1"),
+		testVnode("h4", "z1", "r1"),
+	)
+
+	// h2 is slow so its location is served by the next successor not in the set
+	op := testVnodeOp(map[string]time.Duration{"h1": 0, "h2": time.Second, "h3": 0})
+	start := time.Now()
+	res, err := rp.Execute(context.Background(), testkey, ConsistencyAll, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("should not wait on the slow replica")
+	}
+	if res.Speculative != 1 {
+		t.Fatal("should have 1 speculative attempt", res.Speculative)
+	}
+	var hosts []string
+	for _, rep := range res.Succeeded {
+		hosts = append(hosts, rep.Location.Vnode.Host)
+	}
+	if len(hosts) != 2 || (hosts[0] != "h3" && hosts[1] != "h3") {
+		t.Fatal("h3 should serve the slow location", hosts)
+	}
+
+	// Failed replicas move on without waiting
+	conf.SpeculativeDelay = 0
+	op = testVnodeOp(map[string]time.Duration{"h1": 0, "h2": -1, "h3": -1, "h4": 0})
+	if res, err = rp.Execute(context.Background(), testkey, ConsistencyAll, op); err != nil {
+		t.Fatal(err)
+	}
+	if res.Speculative != 0 {
+		t.Fatal("failover should not be speculative")
+	}
+}
+
+func TestReplicated_OverlappingSuccessors(t *testing.T) {
+	conf := &ReplicatedConfig{Replicas: 3, ZoneKey: "zone"}
+	rp := testReplicated(conf,
+		testVnode("h1", "z1", "r1"),
+		testVnode("h2", "z1", "r1"),
+		testVnode("h3", "z1", "r1"),
+		testVnode("h4", "z1", "r1"),
+	)
+
+	// h1 and h2 fail and both have h4 as their only fallback
+	op := testVnodeOp(map[string]time.Duration{"h1": -1, "h2": -1, "h3": 0, "h4": 0})
+	res, err := rp.Execute(context.Background(), testkey, ConsistencyAll, op)
+	if err == nil {
+		t.Fatal("all should not be met with 2 distinct hosts")
+	}
+	testDistinctHosts(t, res)
+
+	if res, err = rp.Execute(context.Background(), testkey, ConsistencyQuorum, op); err != nil {
+		t.Fatal(err)
+	}
+	testDistinctHosts(t, res)
+}
+
+func testDistinctHosts(t *testing.T, res *ReplicatedResult) {
+	seen := map[string]bool{}
+	for _, rep := range res.Succeeded {
+		if seen[rep.Location.Vnode.Host] {
+			t.Fatal("host served more than one location", rep.Location.Vnode.Host)
+		}
+		seen[rep.Location.Vnode.Host] = true
+	}
+}
+
+func TestReplicated_ScourError(t *testing.T) {
+	conf := &ReplicatedConfig{Replicas: 1}
+	rp := testReplicated(conf, testVnode("h1", "z1", "r1"), testVnode("h2", "z1", "r1"))
+	rp.scour = func(ctx context.Context, locID []byte, cb func(*chord.Vnode) error) (int, error) {
+		return 0, fmt.Errorf("lookup timed out")
+	}
+
+	res, err := rp.Execute(context.Background(), testkey, ConsistencyOne, testVnodeOp(nil))
+	if err == nil {
+		t.Fatal("should fail")
+	}
+	if len(res.Failed) != 1 || !strings.Contains(res.Failed[0].Err.Error(), "lookup timed out") {
+		t.Fatal("successor lookup error should be reported", res.Failed)
+	}
+}
+
+func TestNewReplicated(t *testing.T) {
+	r := New(fastConf("127.0.0.1:47789"), NewInMemPeerStore())
+
+	conf := &ReplicatedConfig{ZoneKey: "zone"}
+	if rp := NewReplicated(r, conf); rp.conf.Replicas != 3 || conf.Replicas != 0 {
+		t.Fatal("should default the replicas on a copy")
+	}
+	if rp := NewReplicated(r, nil); rp.conf.Replicas != 3 {
+		t.Fatal("should use the default config")
+	}
+}
@@ -2,202 +2,313 @@ package hexaring
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
 	chord "github.com/hexablock/go-chord"
-	"github.com/hexablock/log"
 )
 
 var errNoPeersFound = errors.New("no peers found")
 
-// Config contains the configuration options for the chord ring.  It augments the
-// default configuration for convenience
-// type Config struct {
-// 	*chord.Config
-
-// 	RPCTimeout  time.Duration
-// 	MaxConnIdle time.Duration
-// }
-//
-
 // Ring is a node part of the chord ring allowing to perform ring operations.  This is
 // used on peers participating in the ring.
 type Ring struct {
 	*chord.Ring                        // Underlying chord ring
-	conf          *chord.Config        // Hexaring config
+	conf          *Config              // Hexaring config
 	peers         PeerStore            // store containing known peers
 	trans         *chord.GRPCTransport // Transport used by chord
+	placement     PlacementStrategy    // Replica placement
+	delegate      *ringDelegate        // Chord delegate for neighborhood changes
+	cache         *lookupCache         // Replicated lookup cache.  nil if disabled
+	ownership     *ownershipNotifier   // Ownership change subscriptions
+	metrics       Metrics              // Operation measurements
+	tracer        Tracer               // Operation spans
+	logger        Logger               // Structured logs
 	lookupService *NetTransport        // Serve up ring operations
 }
 
-// DefaultConfig returns a sane config
-func DefaultConfig(hostname string) *chord.Config {
-	// cfg := &Config{
-	// 	Config:      chord.DefaultConfig(hostname),
-	// 	RPCTimeout:  3 * time.Second,
-	// 	MaxConnIdle: 5 * time.Minute,
-	// }
-	cfg := chord.DefaultConfig(hostname)
-	cfg.NumVnodes = 5                  // lowered from 8
-	cfg.StabilizeMin = 3 * time.Second // lowered from 15
-	cfg.StabilizeMax = 7 * time.Second // lowered from 45
-	//cfg.StabilizeThresh = 30 * time.Second // enables adaptive stabilization
-	return cfg
-}
-
-// New instantiates a new ring
-//func New(conf *Config, peers PeerStore, rpcTimeout, maxConnIdle time.Duration) *Ring {
-func New(conf *chord.Config, peers PeerStore, trans *chord.GRPCTransport) *Ring {
+// New instantiates a new ring.  The chord transport is created using the transport
+// settings in the config.  The chord delegate is wrapped so hexaring can observe ring
+// changes.  The supplied delegate is still called.  The config is copied so the
+// caller's is left untouched.
+func New(conf *Config, peers PeerStore) *Ring {
+	c := *conf
+	if conf.Config != nil {
+		cc := *conf.Config
+		c.Config = &cc
+	}
+	conf = &c
+
 	r := &Ring{
-		conf:  conf,
-		peers: peers,
-		//trans: chord.NewGRPCTransport(rpcTimeout, maxConnIdle),
-		trans: trans,
+		conf:    conf,
+		peers:   peers,
+		trans:   chord.NewGRPCTransport(conf.RPCTimeout, conf.MaxConnIdle),
+		metrics: conf.Metrics,
+		tracer:  conf.Tracer,
+		logger:  conf.Logger,
 	}
+	if r.metrics == nil {
+		r.metrics = NopMetrics{}
+	}
+	if r.tracer == nil {
+		r.tracer = NopTracer{}
+	}
+	if r.logger == nil {
+		r.logger = defaultLogger()
+	}
+
+	if r.placement = conf.Placement; r.placement == nil {
+		p := NewEquidistantPlacement(conf.FailureDomains...)
+		p.SetWeightKey(conf.WeightKey)
+		p.SetLogger(r.logger)
+		r.placement = p
+	}
+
+	r.delegate = newRingDelegate(conf.Delegate)
+	conf.Delegate = r.delegate
+
+	if conf.LookupCache.Size > 0 {
+		r.cache = newLookupCache(conf.LookupCache.Size, conf.LookupCache.TTL)
+		// Our neighborhood changed so cached locations may be stale
+		r.delegate.register(r.cache.purge)
+	}
+
+	r.ownership = newOwnershipNotifier()
+	r.delegate.registerPredecessor(r.ownership.newPredecessor)
+
 	r.lookupService = NewNetTransport(r)
 
 	return r
 }
 
-// RegisterServer registers the underlying transport to the grpc server
+// RegisterServer registers the underlying transport to the grpc server.  When the config
+// has Security set the lookup service should be registered to a separate server created
+// with ServerOptions, as the chord transport does not dial using TLS.
 func (r *Ring) RegisterServer(server *grpc.Server) {
-	// Register chord transport
+	r.RegisterChordServer(server)
+	r.RegisterLookupServer(server)
+}
+
+// RegisterChordServer registers only the chord transport to the grpc server
+func (r *Ring) RegisterChordServer(server *grpc.Server) {
 	r.trans.RegisterServer(server)
-	// Register hexing lookup service
+}
+
+// RegisterLookupServer registers only the hexaring lookup service to the grpc server
+func (r *Ring) RegisterLookupServer(server *grpc.Server) {
 	r.lookupService.RegisterServer(server)
 }
 
+// ServerOptions returns the grpc server options for the lookup service based on the
+// Security and Auth config.  It returns no options if neither is set.
+func (r *Ring) ServerOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if r.conf.Security != nil {
+		creds, err := NewTLSCredentials(r.conf.Security)
+		if err != nil {
+			return nil, err
+		}
+		creds.SetLogger(r.logger)
+		opts = append(opts, creds.ServerOption())
+	}
+
+	if r.conf.Auth != nil {
+		opts = append(opts,
+			grpc.UnaryInterceptor(r.conf.Auth.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(r.conf.Auth.StreamServerInterceptor()),
+		)
+	}
+
+	return opts, nil
+}
+
 // LookupReplicated returns vnodes where a key and n replicas are located.
 func (r *Ring) LookupReplicated(key []byte, n int) (LocationSet, error) {
+	return r.LookupReplicatedCtx(context.Background(), key, n)
+}
+
+// LookupReplicatedCtx returns vnodes where a key and n replicas are located.  The lookup
+// is aborted when the context is cancelled or its deadline is exceeded.
+func (r *Ring) LookupReplicatedCtx(ctx context.Context, key []byte, n int) (LocationSet, error) {
+	return r.LookupReplicatedHashCtx(ctx, r.hashKey(key), n)
+}
+
+// hashKey returns the natural key hash using the configured hash function
+func (r *Ring) hashKey(key []byte) []byte {
 	h := r.conf.HashFunc()
 	h.Write(key)
-	sh := h.Sum(nil)
-	return r.LookupReplicatedHash(sh[:], n)
+	return h.Sum(nil)
 }
 
-// LookupReplicatedHashSerial returns vnodes where a key and n replicas are located.
-// Each replica returned is a unique node.  It returns a n error if the lookup fails or
-// enough unique nodes are not found.
+// LookupReplicatedHashSerial returns vnodes where a key and n replicas are located as
+// chosen by the configured PlacementStrategy making one successor lookup at a time.
+// Each replica returned is a unique node spread across the configured failure
+// domains.  It returns an error if the lookup fails or enough unique nodes are not
+// found.
 func (r *Ring) LookupReplicatedHashSerial(hash []byte, n int) (LocationSet, error) {
+	return r.LookupReplicatedHashSerialCtx(context.Background(), hash, n)
+}
 
-	hashes := CalculateRingVertexBytes(hash, int64(n))
-	locations := map[string]*Location{}
+// LookupReplicatedHashSerialCtx is the context aware version of
+// LookupReplicatedHashSerial.  Lookups not yet started are skipped once the context is
+// done.
+func (r *Ring) LookupReplicatedHashSerialCtx(ctx context.Context, hash []byte, n int) (LocationSet, error) {
+	ctx, span := r.tracer.Start(ctx, "Ring.LookupReplicatedHashSerial")
+	span.SetAttribute(LabelReplicas, n)
+
+	start := time.Now()
+	locs, err := r.lookupReplicatedHashSerial(ctx, hash, n)
+	r.metrics.Observe(MetricLookupDuration, rpcLabels("LookupReplicatedHashSerial", n, err), time.Since(start).Seconds())
+	endSpan(span, err)
+	return locs, err
+}
 
-	for i, h := range hashes {
-		// Lookup successors for the replicated hash with the maximum allowable
-		// successors.
-		vs, err := r.LookupHash(r.conf.NumSuccessors, h)
-		if err != nil {
-			return nil, err
-		}
-		// Go through each successor selecting the first one by host that we do not have.
-		for j, vn := range vs {
-			if _, ok := locations[vn.Host]; !ok {
-				locations[vn.Host] = &Location{
-					ID:       h,
-					Priority: int32(i),
-					Index:    int32(j),
-					Vnode:    vn,
-				}
-				break
-			}
-		}
+func (r *Ring) lookupReplicatedHashSerial(ctx context.Context, hash []byte, n int) (LocationSet, error) {
+	return r.placement.Place(ctx, serialLookup(r.successors), hash, n)
+}
+
+// serialLookup returns a SuccessorLookup making one lookup at a time so a placement
+// looking up several hashes concurrently looks them up serially
+func serialLookup(lookup SuccessorLookup) SuccessorLookup {
+	var mu sync.Mutex
+	return func(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return lookup(ctx, hash)
 	}
+}
 
-	// Check if we have the requested number of replicas
-	if len(locations) != n {
-		return nil, fmt.Errorf("not enough hosts found")
+// LookupReplicatedHash returns vnodes where a key and n replicas are located as chosen by
+// the configured PlacementStrategy.  Each replica returned is a unique node spread
+// across the configured failure domains.  It returns an error if the lookup fails or
+// enough unique nodes are not found.
+func (r *Ring) LookupReplicatedHash(hash []byte, n int) (LocationSet, error) {
+	return r.LookupReplicatedHashCtx(context.Background(), hash, n)
+}
+
+// LookupReplicatedHashCtx is the context aware version of LookupReplicatedHash.  It
+// returns the context error as soon as the context is done without waiting on any
+// in-flight lookups.  Results are served from the lookup cache if enabled unless the
+// context was created with WithoutCache.
+func (r *Ring) LookupReplicatedHashCtx(ctx context.Context, hash []byte, n int) (LocationSet, error) {
+	ctx, span := r.tracer.Start(ctx, "Ring.LookupReplicatedHash")
+	span.SetAttribute(LabelReplicas, n)
+
+	start := time.Now()
+	locs, err := r.lookupReplicatedHash(ctx, r.successors, hash, n)
+	r.metrics.Observe(MetricLookupDuration, rpcLabels("LookupReplicatedHash", n, err), time.Since(start).Seconds())
+	endSpan(span, err)
+	return locs, err
+}
+
+// lookupReplicatedHash places the hash using the given successor lookup and the lookup
+// cache if enabled
+func (r *Ring) lookupReplicatedHash(ctx context.Context, lookup SuccessorLookup, hash []byte, n int) (LocationSet, error) {
+	useCache := r.cache != nil && !cacheDisabled(ctx)
+	var gen uint64
+	if useCache {
+		if locs, ok := r.cache.get(hash, n); ok {
+			return locs, nil
+		}
+		gen = r.cache.generation()
 	}
 
-	// Re-arrange by highest priority first
-	locs := make(LocationSet, n)
-	for _, v := range locations {
-		locs[v.Priority] = v
+	locs, err := r.placement.Place(ctx, lookup, hash, n)
+	if err == nil && useCache {
+		r.cache.set(gen, hash, n, locs)
 	}
+	return locs, err
+}
 
-	return locs, nil
+// InvalidateCache removes all cached lookup results.  It is a no-op if the cache is not
+// enabled.
+func (r *Ring) InvalidateCache() {
+	if r.cache != nil {
+		r.cache.purge()
+	}
 }
 
-// LookupReplicatedHash returns vnodes where a key and n replicas are located.  Each
-// replica call is performed in its own go-routine. Each replica returned is a
-// unique node.  It returns a n error if the lookup fails or enough unique nodes are
-// not found.
-func (r *Ring) LookupReplicatedHash(hash []byte, n int) (LocationSet, error) {
-	hashes := CalculateRingVertexBytes(hash, int64(n))
-	out := make(chan []*Location, n)
-
-	var wg sync.WaitGroup
-	wg.Add(n)
-	for i, h := range hashes {
-		// Lookup successors for the replicated hash with the maximum allowable
-		// successors.
-		go func(idx int, hsh []byte) {
-
-			vs, err := r.LookupHash(r.conf.NumSuccessors, hsh)
-			if err != nil {
-				log.Println("[ERROR] Lookup failed:", err)
-				//	return nil, err
-				out <- nil
-				return
-			}
+// PlaceCtx returns the n replica locations for the hash as chosen by the given placement
+// strategy rather than the configured one.  This allows a different strategy per
+// keyspace.
+func (r *Ring) PlaceCtx(ctx context.Context, p PlacementStrategy, hash []byte, n int) (LocationSet, error) {
+	return p.Place(ctx, r.successors, hash, n)
+}
 
-			locs := make([]*Location, len(vs))
-			for j, v := range vs {
-				locs[j] = &Location{ID: hsh, Vnode: v, Index: int32(j), Priority: int32(idx)}
-			}
-			out <- locs
+// successors returns the max allowed successors for the hash.  It satisfies the
+// SuccessorLookup signature used by placement strategies
+func (r *Ring) successors(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+	return r.lookupHash(ctx, r.conf.NumSuccessors, hash)
+}
 
-			wg.Done()
+// lookup performs a chord Lookup returning early with the context error if the context
+// is done before the lookup completes.
+func (r *Ring) lookup(ctx context.Context, n int, key []byte) (hash []byte, vns []*chord.Vnode, err error) {
+	_, span := r.tracer.Start(ctx, "chord.Lookup")
+	span.SetAttribute("successors", n)
+	defer func() { endSpan(span, err) }()
 
-		}(i, h)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
+	type result struct {
+		hash []byte
+		vns  []*chord.Vnode
+		err  error
 	}
 
+	ch := make(chan result, 1)
 	go func() {
-		wg.Wait()
-		close(out)
+		h, vns, err := r.Lookup(n, key)
+		ch <- result{h, vns, err}
 	}()
 
-	locations := make([][]*Location, n)
-	// Sort by priority
-	for la := range out {
-		if la == nil {
-			return nil, fmt.Errorf("not enough hosts found")
-		}
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case res := <-ch:
+		return res.hash, res.vns, res.err
+	}
+}
 
-		p := la[0].Priority
-		locations[p] = la
+// lookupHash performs a chord LookupHash returning early with the context error if the
+// context is done before the lookup completes.  Each replica vertex lookup goes through
+// here so it is traced as its own span.
+func (r *Ring) lookupHash(ctx context.Context, n int, hash []byte) (vns []*chord.Vnode, err error) {
+	_, span := r.tracer.Start(ctx, "chord.LookupHash")
+	span.SetAttribute("hash", hex.EncodeToString(hash))
+	span.SetAttribute("successors", n)
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	locs := make(LocationSet, n)
-	locs[0] = locations[0][0]
+	type result struct {
+		vns []*chord.Vnode
+		err error
+	}
 
-	// Update list with uniques by priority
-	c := 1
-	for _, l := range locations[1:] {
-		for _, ll := range l {
-			if containsHost(locs[:c], ll.Host()) {
-				continue
-			}
+	ch := make(chan result, 1)
+	go func() {
+		vns, err := r.LookupHash(n, hash)
+		ch <- result{vns, err}
+	}()
 
-			locs[c] = ll
-			c++
-			break
-		}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res.vns, res.err
 	}
-	// Make sure we have the requested count
-	for i := n - 1; i >= 0; i-- {
-		if locs[i] == nil {
-			return locs[:i], fmt.Errorf("not enough hosts found")
-		}
-	}
-
-	return locs, nil
 }
 
 // Hostname returns the hostname of the node per the config.
@@ -213,6 +324,18 @@ func (r *Ring) NumSuccessors() int {
 // ScourSector scours all nodes between start and end hashes issueing the callback for
 // the chosen vnodes.  It skips nodes that have already been visited.
 func (r *Ring) ScourSector(start, end []byte, cb func(*chord.Vnode) error) (int, error) {
+	return r.ScourSectorCtx(context.Background(), start, end, cb)
+}
+
+// ScourSectorCtx is the context aware version of ScourSector.  It stops traversing the
+// sector once the context is done returning the context error.
+func (r *Ring) ScourSectorCtx(ctx context.Context, start, end []byte, cb func(*chord.Vnode) error) (int, error) {
+	visited, err := r.scourSector(ctx, start, end, cb)
+	r.observeScour("ScourSector", visited, err)
+	return visited, err
+}
+
+func (r *Ring) scourSector(ctx context.Context, start, end []byte, cb func(*chord.Vnode) error) (int, error) {
 	var (
 		cfunc func(a, b []byte) int
 		max   = maxHash(len(start))
@@ -241,7 +364,7 @@ func (r *Ring) ScourSector(start, end []byte, cb func(*chord.Vnode) error) (int,
 	lkh := start
 	for {
 
-		vns, err := r.LookupHash(r.conf.NumSuccessors, lkh)
+		vns, err := r.lookupHash(ctx, r.conf.NumSuccessors, lkh)
 		if err != nil {
 			return len(visited), err
 		}
@@ -272,8 +395,20 @@ func (r *Ring) ScourSector(start, end []byte, cb func(*chord.Vnode) error) (int,
 
 // ScourReplica scours a replica location id upto the allowable number of vnodes.
 func (r *Ring) ScourReplica(locID []byte, cb func(*chord.Vnode) error) (int, error) {
+	return r.ScourReplicaCtx(context.Background(), locID, cb)
+}
+
+// ScourReplicaCtx is the context aware version of ScourReplica.  No further callbacks
+// are issued once the context is done.
+func (r *Ring) ScourReplicaCtx(ctx context.Context, locID []byte, cb func(*chord.Vnode) error) (int, error) {
+	visited, err := r.scourReplica(ctx, locID, cb)
+	r.observeScour("ScourReplica", visited, err)
+	return visited, err
+}
+
+func (r *Ring) scourReplica(ctx context.Context, locID []byte, cb func(*chord.Vnode) error) (int, error) {
 	visited := map[string]struct{}{}
-	vns, err := r.LookupHash(r.conf.NumSuccessors, locID)
+	vns, err := r.lookupHash(ctx, r.conf.NumSuccessors, locID)
 	if err != nil {
 		return 0, err
 	}
@@ -283,6 +418,10 @@ func (r *Ring) ScourReplica(locID []byte, cb func(*chord.Vnode) error) (int, err
 		if _, ok := visited[vn.Host]; ok {
 			continue
 		}
+
+		if err = ctx.Err(); err != nil {
+			return len(visited), err
+		}
 		visited[vn.Host] = struct{}{}
 
 		// Return if callback returns an error
@@ -300,10 +439,31 @@ func (r *Ring) ScourReplica(locID []byte, cb func(*chord.Vnode) error) (int, err
 // returns an error, it is immediately exits.  It returns the number of nodes visited
 // and/or an error either from the lookup or callback.
 func (r *Ring) Scour(locs LocationSet, cb func(*chord.Vnode) error) (int, error) {
+	return r.ScourCtx(context.Background(), locs, cb)
+}
+
+// ScourCtx is the context aware version of Scour.  It exits with the context error once
+// the context is done.
+func (r *Ring) ScourCtx(ctx context.Context, locs LocationSet, cb func(*chord.Vnode) error) (int, error) {
+	visited, err := r.scour(ctx, locs, cb)
+	r.observeScour("Scour", visited, err)
+	return visited, err
+}
+
+// observeScour records the hosts visited by a scour
+func (r *Ring) observeScour(method string, visited int, err error) {
+	r.metrics.Observe(MetricScourVisited, rpcLabels(method, 0, err), float64(visited))
+}
+
+func (r *Ring) scour(ctx context.Context, locs LocationSet, cb func(*chord.Vnode) error) (int, error) {
 	// Visited hosts
 	visited := map[string]struct{}{}
 	// Query primary replica locations first
 	for _, loc := range locs {
+		if err := ctx.Err(); err != nil {
+			return len(visited), err
+		}
+
 		visited[loc.Vnode.Host] = struct{}{}
 		// Return if callback returns an error
 		if err := cb(loc.Vnode); err != nil {
@@ -315,8 +475,11 @@ func (r *Ring) Scour(locs LocationSet, cb func(*chord.Vnode) error) (int, error)
 	// Query succesors of each replica location
 	for _, loc := range locs {
 		// Get succesors of location.  Continue to the next if we fail
-		vns, er := r.LookupHash(r.conf.NumSuccessors, loc.ID)
+		vns, er := r.lookupHash(ctx, r.conf.NumSuccessors, loc.ID)
 		if er != nil {
+			if er == ctx.Err() {
+				return len(visited), er
+			}
 			err = er
 			continue
 		}
@@ -327,6 +490,10 @@ func (r *Ring) Scour(locs LocationSet, cb func(*chord.Vnode) error) (int, error)
 			if _, ok := visited[vn.Host]; ok {
 				continue
 			}
+
+			if er = ctx.Err(); er != nil {
+				return len(visited), er
+			}
 			visited[vn.Host] = struct{}{}
 
 			// Return if callback returns an error
@@ -343,17 +510,27 @@ func (r *Ring) Scour(locs LocationSet, cb func(*chord.Vnode) error) (int, error)
 // ScourReplicatedKey finds the replca hash locations for the given key and calls Scour on
 // each location.  This is a helper function to Scour.
 func (r *Ring) ScourReplicatedKey(key []byte, replicas int, cb func(*chord.Vnode) error) (int, error) {
-	locs, err := r.LookupReplicated(key, replicas)
+	return r.ScourReplicatedKeyCtx(context.Background(), key, replicas, cb)
+}
+
+// ScourReplicatedKeyCtx is the context aware version of ScourReplicatedKey.  The context
+// applies to both the replicated lookup and the scour.
+func (r *Ring) ScourReplicatedKeyCtx(ctx context.Context, key []byte, replicas int, cb func(*chord.Vnode) error) (int, error) {
+	locs, err := r.LookupReplicatedCtx(ctx, key, replicas)
 	if err != nil {
 		return 0, err
 	}
 
-	return r.Scour(locs, cb)
+	return r.ScourCtx(ctx, locs, cb)
 }
 
 // Create creates a new ring.  This is only to be called once.
 func (r *Ring) Create() error {
-	ring, err := chord.Create(r.conf, r.trans)
+	if err := r.conf.Validate(); err != nil {
+		return err
+	}
+
+	ring, err := chord.Create(r.conf.Config, r.trans)
 	if err == nil {
 		r.Ring = ring
 	}
@@ -362,62 +539,84 @@ func (r *Ring) Create() error {
 
 // Join tries to join an existing ring using any of the peers from the PeerStore.
 func (r *Ring) Join() error {
-	return joinRing(r, r.peers)
+	if err := r.conf.Validate(); err != nil {
+		return err
+	}
+	err := joinRing(context.Background(), r, r.peers)
+	r.metrics.IncrCounter(MetricJoinAttempts, map[string]string{LabelOutcome: metricOutcome(err)}, 1)
+	return err
 }
 
-// RetryJoin keeps looping through the available peers to join.  It implements a backoff
-// for each retry
+// RetryJoin keeps looping through the available peers to join.  It waits between each
+// round per the configured JoinBackoff and gives up once the backoff allows no more
+// attempts or the RetryJoinTimeout is reached.
 func (r *Ring) RetryJoin() error {
+	ctx := context.Background()
+	if r.conf.RetryJoinTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.conf.RetryJoinTimeout)
+		defer cancel()
+	}
+	return r.RetryJoinContext(ctx)
+}
 
-	retryInSec := 2
-	tries := 0
+// RetryJoinContext is the same as RetryJoin but also stops once the context is done
+// returning the context error.
+func (r *Ring) RetryJoinContext(ctx context.Context) error {
+	if err := r.conf.Validate(); err != nil {
+		return err
+	}
 
-	for {
-		tries++
-		if tries == 3 {
-			tries = 0
-			retryInSec *= retryInSec
-		}
+	for attempt := 1; ; attempt++ {
 		// Try each set of peers
-		err := joinRing(r, r.peers)
+		err := joinRing(ctx, r, r.peers)
+		r.metrics.IncrCounter(MetricJoinAttempts, map[string]string{LabelOutcome: metricOutcome(err)}, 1)
 		if err == nil {
 			return nil
 		}
-		log.Printf("Failed to connect msg='%v'", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.logger.Warn("Failed to join ring", FieldAttempt, attempt, FieldError, errField(err))
+
+		wait, ok := r.conf.JoinBackoff.Next(attempt)
+		if !ok {
+			return fmt.Errorf("join attempts exhausted: %v", err)
+		}
 
 		// Wait before retying
-		log.Printf("Trying in %d secs ...", retryInSec)
-		<-time.After(time.Duration(retryInSec) * time.Second)
+		r.logger.Info("Retrying join", FieldAttempt, attempt, "wait", wait.String())
+		r.metrics.IncrCounter(MetricJoinRetries, nil, 1)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
 }
 
 // helper for join and retry-join
-func joinRing(r *Ring, peerStore PeerStore) error {
+func joinRing(ctx context.Context, r *Ring, peerStore PeerStore) error {
 
 	peers := peerStore.Peers()
 	for _, peer := range peers {
-		log.Printf("[INFO] Trying peer=%s", peer)
+		r.logger.Info("Trying peer", FieldPeer, peer)
 
-		ring, err := chord.Join(r.conf, r.trans, peer)
+		ring, err := chord.Join(r.conf.Config, r.trans, peer)
 		if err == nil {
 			r.Ring = ring
 			return nil
 		}
-		log.Printf("[ERROR] Failed to connect peer=%s msg='%v'", peer, err)
+		r.logger.Error("Failed to join peer", FieldPeer, peer, FieldError, errField(err))
 
 		// Wait before trying next peer
-		<-time.After(500 * time.Millisecond)
+		select {
+		case <-time.After(r.conf.JoinPeerDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
 	return fmt.Errorf("all peers exhausted")
 }
-
-func containsHost(locs []*Location, host string) bool {
-	for _, v := range locs {
-		if v.Host() == host {
-			return true
-		}
-	}
-	return false
-}
@@ -2,10 +2,13 @@ package hexaring
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
 	chord "github.com/hexablock/go-chord"
@@ -13,13 +16,15 @@ import (
 
 var testkey = []byte("testkey")
 
-func fastConf(host string) *chord.Config {
+func fastConf(host string) *Config {
 	conf := DefaultConfig(host)
 	conf.Meta = chord.Meta{"key": []byte("test")}
 	conf.StabilizeMin = time.Duration(15 * time.Millisecond)
 	conf.StabilizeMax = time.Duration(45 * time.Millisecond)
 	// enabled adaptive stabilization
 	conf.StabilizeThresh = time.Duration(30 * time.Millisecond)
+	conf.RPCTimeout = 2 * time.Second
+	conf.MaxConnIdle = 1 * time.Minute
 	return conf
 }
 
@@ -37,9 +42,7 @@ func initTestRing(host string, peers ...string) (*Ring, error) {
 		ps.AddPeer(p)
 	}
 
-	trans := chord.NewGRPCTransport(2*time.Second, 1*time.Minute)
-
-	r := New(conf, ps, trans)
+	r := New(conf, ps)
 	r.RegisterServer(server)
 
 	go server.Serve(ln)
@@ -235,3 +238,127 @@ func TestRing_ScourReplicatedKey(t *testing.T) {
 	}
 
 }
+
+func TestRing_Ctx(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:34445")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	r2, err := initTestRing("127.0.0.1:45556", "127.0.0.1:34445")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	locs, err := r2.LookupReplicatedCtx(ctx, testkey, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 2 {
+		t.Fatal("should have 2 locations")
+	}
+
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+
+	if _, err = r1.LookupReplicatedCtx(cctx, testkey, 2); err != context.Canceled {
+		t.Fatal("should fail with context canceled", err)
+	}
+	if _, err = r1.LookupReplicatedHashSerialCtx(cctx, locs[0].ID, 2); err != context.Canceled {
+		t.Fatal("should fail with context canceled", err)
+	}
+
+	var c int
+	if _, err = r1.ScourCtx(cctx, locs, func(*chord.Vnode) error {
+		c++
+		return nil
+	}); err != context.Canceled {
+		t.Fatal("should fail with context canceled", err)
+	}
+	if c != 0 {
+		t.Fatal("callback should not be called")
+	}
+
+	if _, err = r1.ScourReplicaCtx(cctx, locs[0].ID, func(*chord.Vnode) error { return nil }); err != context.Canceled {
+		t.Fatal("should fail with context canceled", err)
+	}
+}
+
+func TestRing_RetryJoinContext(t *testing.T) {
+	conf := fastConf("127.0.0.1:46667")
+	conf.JoinPeerDelay = 10 * time.Millisecond
+	conf.JoinBackoff = &ExponentialBackoff{
+		Initial:     10 * time.Millisecond,
+		Multiplier:  2,
+		MaxAttempts: 2,
+	}
+
+	ps := NewInMemPeerStore()
+	ps.AddPeer("127.0.0.1:65431")
+
+	r := New(conf, ps)
+	if err := r.RetryJoin(); err == nil {
+		t.Fatal("should fail once attempts are exhausted")
+	}
+
+	r.conf.JoinBackoff = &ExponentialBackoff{Initial: time.Second, Multiplier: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := r.RetryJoinContext(ctx); err != context.DeadlineExceeded {
+		t.Fatal("should fail with deadline exceeded", err)
+	}
+}
+
+func TestRing_RetryJoinAttempts(t *testing.T) {
+	conf := fastConf("127.0.0.1:46668")
+	conf.JoinBackoff = &ExponentialBackoff{Initial: time.Millisecond, Multiplier: 1, MaxAttempts: 3}
+
+	// No peers so every join fails
+	r := New(conf, NewInMemPeerStore())
+	pm := NewPrometheusMetrics()
+	r.metrics = pm
+	if err := r.RetryJoin(); err == nil {
+		t.Fatal("should fail once attempts are exhausted")
+	}
+
+	out := pm.Bytes()
+	if !bytes.Contains(out, []byte(MetricJoinAttempts+`{outcome="error"} 3`)) {
+		t.Fatalf("should join MaxAttempts times\n%s", out)
+	}
+	if !bytes.Contains(out, []byte(MetricJoinRetries+" 2")) {
+		t.Fatalf("should retry MaxAttempts-1 times\n%s", out)
+	}
+}
+
+func TestSerialLookup(t *testing.T) {
+	lookup := testRingLookup(testRingVnodes("h1", "h2", "h3", "h4"), 8)
+	var active, max int32
+	serial := serialLookup(func(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+		if n := atomic.AddInt32(&active, 1); n > atomic.LoadInt32(&max) {
+			atomic.StoreInt32(&max, n)
+		}
+		time.Sleep(time.Millisecond)
+		defer atomic.AddInt32(&active, -1)
+		return lookup(ctx, hash)
+	})
+
+	hash := sha1.Sum(testkey)
+	for _, p := range []PlacementStrategy{NewEquidistantPlacement(), NewSuccessorPlacement()} {
+		locs, err := p.Place(context.Background(), serial, hash[:], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := p.Place(context.Background(), lookup, hash[:], 3)
+		if locs.String() != want.String() {
+			t.Fatalf("%T should place the same as a parallel lookup", p)
+		}
+	}
+	if max != 1 {
+		t.Fatal("lookups should be serial", max)
+	}
+}
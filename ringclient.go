@@ -0,0 +1,377 @@
+package hexaring
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// RingClientConfig contains the retry options of a RingClient
+type RingClientConfig struct {
+	// Wait between attempts.  It must stop after a finite number of attempts as calls
+	// without a context deadline are otherwise retried forever.  Nil uses the default
+	Backoff Backoff
+	// Time a failing host is avoided for
+	UnhealthyTTL time.Duration
+	// Add hosts seen in responses to the peer store.  Off by default as vnode hosts are
+	// chord transport addresses
+	LearnPeers bool
+	// Maps a vnode seen in a response to the address of its lookup service.  Returning an
+	// empty string skips it.  Nil uses the vnode host which is only correct when the
+	// lookup service shares the chord listener
+	PeerAddress func(vn *chord.Vnode) string
+}
+
+// DefaultRingClientConfig returns a sane config
+func DefaultRingClientConfig() *RingClientConfig {
+	return &RingClientConfig{
+		Backoff: &ExponentialBackoff{
+			Initial:     50 * time.Millisecond,
+			Max:         2 * time.Second,
+			Multiplier:  2,
+			Jitter:      0.2,
+			MaxAttempts: 5,
+		},
+		UnhealthyTTL: 30 * time.Second,
+	}
+}
+
+// RingClient performs lookups against any member of the ring.  Calls are retried
+// against other members from the peer store when a member fails.  Only idempotent
+// lookups are provided.
+type RingClient struct {
+	conf   *RingClientConfig
+	client *NetClient
+	peers  PeerStore
+	logger Logger
+
+	mu        sync.Mutex
+	unhealthy map[string]time.Time
+
+	// round robin counter
+	next uint32
+}
+
+// NewRingClient instantiates a RingClient making calls with the NetClient to the hosts
+// in the peer store.  A nil config or backoff takes the default.  It returns an error if
+// an ExponentialBackoff is invalid or does not limit the attempts.
+func NewRingClient(conf *RingClientConfig, client *NetClient, peers PeerStore) (*RingClient, error) {
+	// Copied so defaults are not written to the caller's config
+	c := DefaultRingClientConfig()
+	if conf != nil {
+		cp := *conf
+		if cp.Backoff == nil {
+			cp.Backoff = c.Backoff
+		}
+		c = &cp
+	}
+	if eb, ok := c.Backoff.(*ExponentialBackoff); ok {
+		if err := eb.Validate(); err != nil {
+			return nil, err
+		}
+		if eb.MaxAttempts == 0 {
+			return nil, fmt.Errorf("ring client backoff max attempts must be > 0")
+		}
+	}
+
+	rc := &RingClient{
+		conf:      c,
+		client:    client,
+		peers:     peers,
+		logger:    defaultLogger(),
+		unhealthy: make(map[string]time.Time),
+	}
+	if client != nil {
+		rc.logger = client.logger
+	}
+	return rc, nil
+}
+
+// Lookup performs a key lookup on any member
+func (rc *RingClient) Lookup(n int32, key []byte) ([]*chord.Vnode, error) {
+	return rc.LookupCtx(context.Background(), n, key)
+}
+
+// LookupCtx is the context aware version of Lookup.  The context spans all attempts.
+func (rc *RingClient) LookupCtx(ctx context.Context, n int32, key []byte) (vns []*chord.Vnode, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if vns, err = rc.client.LookupCtx(ctx, host, n, key); err == nil {
+			rc.learnVnodes(vns)
+		}
+		return err
+	})
+	return
+}
+
+// LookupHash performs a hash lookup on any member
+func (rc *RingClient) LookupHash(n int32, hash []byte) ([]*chord.Vnode, error) {
+	return rc.LookupHashCtx(context.Background(), n, hash)
+}
+
+// LookupHashCtx is the context aware version of LookupHash.  The context spans all
+// attempts.
+func (rc *RingClient) LookupHashCtx(ctx context.Context, n int32, hash []byte) (vns []*chord.Vnode, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if vns, err = rc.client.LookupHashCtx(ctx, host, n, hash); err == nil {
+			rc.learnVnodes(vns)
+		}
+		return err
+	})
+	return
+}
+
+// LookupReplicated performs a replicated key lookup on any member
+func (rc *RingClient) LookupReplicated(key []byte, n int32) ([]*Location, error) {
+	return rc.LookupReplicatedCtx(context.Background(), key, n)
+}
+
+// LookupReplicatedCtx is the context aware version of LookupReplicated.  The context
+// spans all attempts.
+func (rc *RingClient) LookupReplicatedCtx(ctx context.Context, key []byte, n int32) (locs []*Location, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if locs, err = rc.client.LookupReplicatedCtx(ctx, host, key, n); err == nil {
+			rc.learnLocations(locs)
+		}
+		return err
+	})
+	return
+}
+
+// LookupReplicatedHash performs a replicated hash lookup on any member
+func (rc *RingClient) LookupReplicatedHash(hash []byte, n int32) ([]*Location, error) {
+	return rc.LookupReplicatedHashCtx(context.Background(), hash, n)
+}
+
+// LookupReplicatedHashCtx is the context aware version of LookupReplicatedHash.  The
+// context spans all attempts.
+func (rc *RingClient) LookupReplicatedHashCtx(ctx context.Context, hash []byte, n int32) (locs []*Location, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if locs, err = rc.client.LookupReplicatedHashCtx(ctx, host, hash, n); err == nil {
+			rc.learnLocations(locs)
+		}
+		return err
+	})
+	return
+}
+
+// LookupHashBatch performs a batch hash lookup on any member
+func (rc *RingClient) LookupHashBatch(n int32, hashes [][]byte) ([]*LookupResult, error) {
+	return rc.LookupHashBatchCtx(context.Background(), n, hashes)
+}
+
+// LookupHashBatchCtx is the context aware version of LookupHashBatch.  The context
+// spans all attempts.
+func (rc *RingClient) LookupHashBatchCtx(ctx context.Context, n int32, hashes [][]byte) (res []*LookupResult, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if res, err = rc.client.LookupHashBatchCtx(ctx, host, n, hashes); err == nil {
+			rc.learnResults(res)
+		}
+		return err
+	})
+	return
+}
+
+// LookupReplicatedBatch performs a batch replicated key lookup on any member
+func (rc *RingClient) LookupReplicatedBatch(keys [][]byte, n int32) ([]*LookupResult, error) {
+	return rc.LookupReplicatedBatchCtx(context.Background(), keys, n)
+}
+
+// LookupReplicatedBatchCtx is the context aware version of LookupReplicatedBatch.  The
+// context spans all attempts.
+func (rc *RingClient) LookupReplicatedBatchCtx(ctx context.Context, keys [][]byte, n int32) (res []*LookupResult, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if res, err = rc.client.LookupReplicatedBatchCtx(ctx, host, keys, n); err == nil {
+			rc.learnResults(res)
+		}
+		return err
+	})
+	return
+}
+
+// Topology returns a snapshot of all vnodes in the ring from any member
+func (rc *RingClient) Topology() (*Topology, error) {
+	return rc.TopologyCtx(context.Background())
+}
+
+// TopologyCtx is the context aware version of Topology.  The context spans all
+// attempts.
+func (rc *RingClient) TopologyCtx(ctx context.Context) (topo *Topology, err error) {
+	err = rc.do(ctx, func(host string) error {
+		if topo, err = rc.client.TopologyCtx(ctx, host); err == nil {
+			rc.learnVnodes(topo.Vnodes)
+		}
+		return err
+	})
+	return
+}
+
+// do calls f with a host until it succeeds, returns an error that cannot be retried or
+// the backoff stops
+func (rc *RingClient) do(ctx context.Context, f func(host string) error) error {
+	tried := map[string]bool{}
+
+	for attempt := 1; ; attempt++ {
+		host := rc.pick(tried)
+		if host == "" {
+			return fmt.Errorf("no peers")
+		}
+		tried[host] = true
+
+		err := f(host)
+		if err == nil {
+			rc.markHealthy(host)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		retry, hostFailed := retryable(err)
+		if hostFailed {
+			rc.markUnhealthy(host)
+			rc.logger.Warn("Marked host unhealthy", FieldPeer, host, FieldAttempt, attempt,
+				FieldError, errField(err))
+		}
+		if !retry {
+			return err
+		}
+
+		wait, ok := rc.conf.Backoff.Next(attempt)
+		if !ok {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// retryable returns whether the error may succeed on another host and whether it is a
+// failure of the host itself rather than of the request
+func retryable(err error) (retry bool, hostFailed bool) {
+	if err == errPoolShutdown {
+		// The local client is shut down
+		return false, false
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		// Not an rpc error e.g. failed to dial
+		return true, true
+	}
+
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		// Includes the timeouts of the chord calls made by the member
+		return true, true
+	case codes.Aborted, codes.ResourceExhausted:
+		return true, false
+	}
+	// Unknown covers deterministic failures e.g. not enough hosts that would fail the
+	// same on every member
+	return false, false
+}
+
+// pick returns the next host round robin preferring healthy hosts not yet tried, then
+// unhealthy ones not yet tried, then any host.  It returns an empty string if there
+// are no peers.
+func (rc *RingClient) pick(tried map[string]bool) string {
+	peers := rc.peers.Peers()
+	if len(peers) == 0 {
+		return ""
+	}
+	offset := int(atomic.AddUint32(&rc.next, 1))
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	var fallback string
+	now := time.Now()
+	for i := range peers {
+		host := peers[(offset+i)%len(peers)]
+		if tried[host] {
+			continue
+		}
+		if until, ok := rc.unhealthy[host]; ok && now.Before(until) {
+			if fallback == "" {
+				fallback = host
+			}
+			continue
+		}
+		return host
+	}
+
+	if fallback != "" {
+		return fallback
+	}
+	return peers[offset%len(peers)]
+}
+
+func (rc *RingClient) markUnhealthy(host string) {
+	rc.mu.Lock()
+	rc.unhealthy[host] = time.Now().Add(rc.conf.UnhealthyTTL)
+	rc.mu.Unlock()
+}
+
+func (rc *RingClient) markHealthy(host string) {
+	rc.mu.Lock()
+	delete(rc.unhealthy, host)
+	rc.mu.Unlock()
+}
+
+// Unhealthy returns the hosts currently being avoided
+func (rc *RingClient) Unhealthy() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	var out []string
+	now := time.Now()
+	for host, until := range rc.unhealthy {
+		if now.Before(until) {
+			out = append(out, host)
+		} else {
+			delete(rc.unhealthy, host)
+		}
+	}
+	return out
+}
+
+func (rc *RingClient) learnVnodes(vns []*chord.Vnode) {
+	if !rc.conf.LearnPeers {
+		return
+	}
+	for _, vn := range vns {
+		if vn == nil {
+			continue
+		}
+		addr := vn.Host
+		if rc.conf.PeerAddress != nil {
+			addr = rc.conf.PeerAddress(vn)
+		}
+		if addr != "" {
+			rc.peers.AddPeer(addr)
+		}
+	}
+}
+
+func (rc *RingClient) learnLocations(locs []*Location) {
+	for _, loc := range locs {
+		rc.learnVnodes([]*chord.Vnode{loc.Vnode})
+	}
+}
+
+func (rc *RingClient) learnResults(res []*LookupResult) {
+	for _, r := range res {
+		rc.learnVnodes(r.Vnodes)
+		rc.learnLocations(r.Locations)
+	}
+}
@@ -0,0 +1,223 @@
+package hexaring
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func testRingClient(hosts ...string) *RingClient {
+	ps := NewInMemPeerStore()
+	for _, h := range hosts {
+		ps.AddPeer(h)
+	}
+	conf := DefaultRingClientConfig()
+	conf.Backoff = &ExponentialBackoff{Initial: time.Millisecond, Multiplier: 1, MaxAttempts: 4}
+	rc, _ := NewRingClient(conf, nil, ps)
+	return rc
+}
+
+func TestNewRingClient(t *testing.T) {
+	conf := &RingClientConfig{UnhealthyTTL: time.Second}
+	rc, err := NewRingClient(conf, nil, NewInMemPeerStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.conf.Backoff == nil || conf.Backoff != nil {
+		t.Fatal("should default the backoff on a copy")
+	}
+	if rc, err = NewRingClient(nil, nil, NewInMemPeerStore()); err != nil || rc.conf.Backoff == nil {
+		t.Fatal("should default a nil config", err)
+	}
+
+	conf.Backoff = &ExponentialBackoff{Initial: time.Millisecond, Multiplier: 1}
+	if _, err = NewRingClient(conf, nil, NewInMemPeerStore()); err == nil {
+		t.Fatal("should fail with unlimited attempts")
+	}
+	conf.Backoff = &ExponentialBackoff{Multiplier: 1, MaxAttempts: 2}
+	if _, err = NewRingClient(conf, nil, NewInMemPeerStore()); err == nil {
+		t.Fatal("should fail with an invalid backoff")
+	}
+}
+
+func TestRingClient_Failover(t *testing.T) {
+	rc := testRingClient("a", "b", "c")
+	// Start at a
+	rc.next = 2
+
+	var calls []string
+	err := rc.do(context.Background(), func(host string) error {
+		calls = append(calls, host)
+		if host != "c" {
+			return status.Errorf(codes.Unavailable, "down")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0] != "a" || calls[1] != "b" || calls[2] != "c" {
+		t.Fatal("should try each host once", calls)
+	}
+
+	// Failed hosts are avoided
+	if len(rc.Unhealthy()) != 2 {
+		t.Fatal("failed hosts should be unhealthy", rc.Unhealthy())
+	}
+	for i := 0; i < 5; i++ {
+		err = rc.do(context.Background(), func(host string) error {
+			if host != "c" {
+				t.Fatal("unhealthy host picked", host)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRingClient_Errors(t *testing.T) {
+	rc := testRingClient("a", "b")
+
+	// Not retried
+	var n int
+	err := rc.do(context.Background(), func(host string) error {
+		n++
+		return status.Errorf(codes.InvalidArgument, "bad")
+	})
+	if err == nil || n != 1 {
+		t.Fatal("should not retry", n, err)
+	}
+	if len(rc.Unhealthy()) != 0 {
+		t.Fatal("request errors should not mark hosts unhealthy")
+	}
+
+	// Deterministic server failures are not retried
+	n = 0
+	err = rc.do(context.Background(), func(host string) error {
+		n++
+		return status.Errorf(codes.Unknown, "%v", errNotEnoughHosts)
+	})
+	if err == nil || n != 1 {
+		t.Fatal("should not retry unknown errors", n, err)
+	}
+
+	// Bounded by the backoff
+	n = 0
+	err = rc.do(context.Background(), func(host string) error {
+		n++
+		return fmt.Errorf("dial failed")
+	})
+	if err == nil || n != 4 {
+		t.Fatal("should stop after max attempts", n, err)
+	}
+
+	// Local errors
+	n = 0
+	err = rc.do(context.Background(), func(host string) error {
+		n++
+		return errPoolShutdown
+	})
+	if err != errPoolShutdown || n != 1 {
+		t.Fatal("should not retry a shut down client", n, err)
+	}
+
+	// Bounded by the context
+	ctx, cancel := context.WithCancel(context.Background())
+	n = 0
+	err = rc.do(ctx, func(host string) error {
+		n++
+		cancel()
+		return status.Errorf(codes.Unavailable, "down")
+	})
+	if err == nil || n != 1 {
+		t.Fatal("should stop on cancel", n, err)
+	}
+
+	if err = testRingClient().do(context.Background(), func(string) error { return nil }); err == nil {
+		t.Fatal("should fail without peers")
+	}
+}
+
+func TestRingClient_Learn(t *testing.T) {
+	rc := testRingClient("a")
+	results := []*LookupResult{
+		{Vnodes: []*chord.Vnode{{Host: "b"}}},
+		{Locations: []*Location{{Vnode: &chord.Vnode{Host: "c"}}}},
+	}
+	rc.learnResults(results)
+	if len(rc.peers.Peers()) != 1 {
+		t.Fatal("should not learn hosts by default", rc.peers.Peers())
+	}
+
+	rc.conf.LearnPeers = true
+	rc.learnResults(results)
+	if len(rc.peers.Peers()) != 3 {
+		t.Fatal("should learn hosts", rc.peers.Peers())
+	}
+
+	// Lookup service on its own listener
+	rc = testRingClient("a")
+	rc.conf.LearnPeers = true
+	rc.conf.PeerAddress = func(vn *chord.Vnode) string {
+		if vn.Host == "c" {
+			return ""
+		}
+		return vn.Host + ":lookup"
+	}
+	rc.learnResults(results)
+	if peers := rc.peers.Peers(); len(peers) != 2 || peers[1] != "b:lookup" {
+		t.Fatal("should learn mapped hosts", peers)
+	}
+}
+
+func TestRingClient(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:16645")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	if _, err = initTestRing("127.0.0.1:16646", r1.conf.Hostname); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	client := NewNetClient(2*time.Second, 10*time.Second)
+	defer client.Shutdown()
+
+	// Nothing listening on the first peer
+	ps := NewInMemPeerStore()
+	ps.AddPeer("127.0.0.1:16647")
+	ps.AddPeer("127.0.0.1:16645")
+	conf := DefaultRingClientConfig()
+	conf.LearnPeers = true
+	rc, err := NewRingClient(conf, client, ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		locs, err := rc.LookupReplicated(testkey, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locs) != 2 {
+			t.Fatal("should have 2 locations")
+		}
+	}
+
+	unhealthy := rc.Unhealthy()
+	if len(unhealthy) != 1 || unhealthy[0] != "127.0.0.1:16647" {
+		t.Fatal("dead peer should be unhealthy", unhealthy)
+	}
+	if len(ps.Peers()) != 3 {
+		t.Fatal("should learn the second member", ps.Peers())
+	}
+}
@@ -0,0 +1,166 @@
+package hexaring
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// VnodeError is an error for a single vnode.  Vnode is nil if the error occurred
+// looking up successors rather than in a callback.
+type VnodeError struct {
+	Vnode *chord.Vnode
+	Err   error
+}
+
+func (e *VnodeError) Error() string {
+	if e.Vnode == nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s/%x: %v", e.Vnode.Host, e.Vnode.Id, e.Err)
+}
+
+// ScourErrors contains the errors of a parallel scour
+type ScourErrors []*VnodeError
+
+func (errs ScourErrors) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%d scour error(s):", len(errs)))
+	for _, e := range errs {
+		buf.WriteString(" [" + e.Error() + "]")
+	}
+	return buf.String()
+}
+
+// ScourParallel is the concurrent version of Scour.  Callbacks are issued by the given
+// number of workers in the same order vnodes are visited by Scour and hosts are only
+// visited once.  If k > 0 it stops once k callbacks have succeeded otherwise all nodes
+// are visited.  It returns the number of successful callbacks which may exceed k as
+// callbacks already in flight are not interrupted.  A ScourErrors is returned with all
+// callback and lookup errors if k callbacks did not succeed, or for k <= 0, if any error
+// occurred.  A not enough hosts error is returned if fewer than k callbacks succeeded
+// without any error.
+func (r *Ring) ScourParallel(locs LocationSet, workers, k int, cb func(*chord.Vnode) error) (int, error) {
+	return r.ScourParallelCtx(context.Background(), locs, workers, k, cb)
+}
+
+// ScourParallelCtx is the context aware version of ScourParallel.  No further callbacks
+// are issued once the context is done.
+func (r *Ring) ScourParallelCtx(ctx context.Context, locs LocationSet, workers, k int, cb func(*chord.Vnode) error) (int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Cancelled once we have k successes
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		succeeded int
+		errs      ScourErrors
+	)
+	addErr := func(vn *chord.Vnode, err error) {
+		mu.Lock()
+		errs = append(errs, &VnodeError{Vnode: vn, Err: err})
+		mu.Unlock()
+	}
+
+	// A single producer visits hosts so de-duplication needs no locking
+	vnodes := make(chan *chord.Vnode)
+	go func() {
+		defer close(vnodes)
+
+		visited := map[string]struct{}{}
+		send := func(vn *chord.Vnode) bool {
+			if _, ok := visited[vn.Host]; ok {
+				return true
+			}
+			visited[vn.Host] = struct{}{}
+
+			select {
+			case vnodes <- vn:
+				return true
+			case <-sctx.Done():
+				return false
+			}
+		}
+
+		// Primary replica locations first
+		for _, loc := range locs {
+			if !send(loc.Vnode) {
+				return
+			}
+		}
+
+		// Succesors of each replica location
+		for _, loc := range locs {
+			vns, err := r.lookupHash(sctx, r.conf.NumSuccessors, loc.ID)
+			if err != nil {
+				if sctx.Err() != nil {
+					return
+				}
+				addErr(nil, err)
+				continue
+			}
+
+			for _, vn := range vns[1:] {
+				if !send(vn) {
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for vn := range vnodes {
+				if sctx.Err() != nil {
+					continue
+				}
+
+				if err := cb(vn); err != nil {
+					addErr(vn, err)
+					continue
+				}
+
+				mu.Lock()
+				succeeded++
+				if k > 0 && succeeded >= k {
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if k > 0 {
+		if succeeded >= k {
+			return succeeded, nil
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, &VnodeError{Err: err})
+		}
+		if len(errs) == 0 {
+			return succeeded, fmt.Errorf("%w: %d < %d", errNotEnoughHosts, succeeded, k)
+		}
+		return succeeded, errs
+	}
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, &VnodeError{Err: err})
+	}
+	if len(errs) > 0 {
+		return succeeded, errs
+	}
+	return succeeded, nil
+}
@@ -0,0 +1,93 @@
+package hexaring
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func TestRing_ScourParallel(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:15445")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	if _, err = initTestRing("127.0.0.1:25556", "127.0.0.1:15445"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+
+	if _, err = initTestRing("127.0.0.1:25566", "127.0.0.1:15445"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(200 * time.Millisecond)
+
+	locs, err := r1.LookupReplicated([]byte("some-data"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := r1.Scour(locs, func(*chord.Vnode) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]int{}
+	)
+	n, err := r1.ScourParallel(locs, 3, 0, func(vn *chord.Vnode) error {
+		mu.Lock()
+		seen[vn.Host]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != c {
+		t.Fatal("visit count mismatch", n, c)
+	}
+	for h, v := range seen {
+		if v != 1 {
+			t.Fatal("host visited more than once", h)
+		}
+	}
+
+	// First-k
+	n, err = r1.ScourParallel(locs, 1, 2, func(vn *chord.Vnode) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("should stop after 2", n)
+	}
+
+	// Errors are aggregated per vnode
+	n, err = r1.ScourParallel(locs, 2, 0, func(vn *chord.Vnode) error {
+		if vn.Host == locs[0].Host() {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	})
+	errs, ok := err.(ScourErrors)
+	if !ok {
+		t.Fatal("should return scour errors", err)
+	}
+	if len(errs) != 1 || errs[0].Vnode.Host != locs[0].Host() {
+		t.Fatal("wrong errors", errs)
+	}
+	if n != c-1 {
+		t.Fatal("wrong success count", n)
+	}
+
+	// Not enough successes
+	if _, err = r1.ScourParallel(locs, 2, c+1, func(vn *chord.Vnode) error { return nil }); !errors.Is(err, errNotEnoughHosts) {
+		t.Fatal("should fail with not enough hosts")
+	}
+}
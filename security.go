@@ -0,0 +1,250 @@
+package hexaring
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// SecurityConfig contains the TLS settings for the lookup service and its clients.
+// All files are PEM encoded.
+type SecurityConfig struct {
+	// Certificate and key presented by this node.  Required on servers and for mutual
+	// TLS on clients
+	CertFile string
+	KeyFile  string
+	// CA bundle used to verify peers.  The system pool is used if empty
+	CAFile string
+	// Servers require and verify client certificates against the CA i.e. mutual TLS
+	ClientAuth bool
+	// Name used by clients to verify the server certificate.  Defaults to the dialed
+	// host
+	ServerName string
+	// How often the files are checked for changes.  Zero disables reloading
+	ReloadInterval time.Duration
+}
+
+// Validate checks the config for missing files
+func (conf *SecurityConfig) Validate() error {
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return fmt.Errorf("both CertFile and KeyFile are required")
+	}
+	if conf.ClientAuth && conf.CAFile == "" {
+		return fmt.Errorf("CAFile required for client auth")
+	}
+	if conf.ReloadInterval < 0 {
+		return fmt.Errorf("ReloadInterval must be >= 0: %v", conf.ReloadInterval)
+	}
+	return nil
+}
+
+// TLSCredentials holds the loaded certificate and CA pool for a SecurityConfig.  Files
+// are re-read when they change on disk, checked at most once per ReloadInterval as
+// connections are made.  Existing connections are not affected by a reload.
+type TLSCredentials struct {
+	conf   *SecurityConfig
+	logger Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// NewTLSCredentials loads the files in the config returning an error if any cannot be
+// loaded
+func NewTLSCredentials(conf *SecurityConfig) (*TLSCredentials, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	creds := &TLSCredentials{conf: conf, logger: defaultLogger()}
+	if err := creds.load(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func (creds *TLSCredentials) files() []string {
+	var files []string
+	for _, f := range []string{creds.conf.CertFile, creds.conf.KeyFile, creds.conf.CAFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// load reads all files and swaps in the new credentials
+func (creds *TLSCredentials) load() error {
+	modTimes := map[string]time.Time{}
+	for _, f := range creds.files() {
+		st, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = st.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if creds.conf.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(creds.conf.CertFile, creds.conf.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if creds.conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(creds.conf.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found: %s", creds.conf.CAFile)
+		}
+	}
+
+	creds.mu.Lock()
+	creds.cert = cert
+	creds.pool = pool
+	creds.modTimes = modTimes
+	creds.lastCheck = time.Now()
+	creds.mu.Unlock()
+
+	return nil
+}
+
+// SetLogger sets the logger for reload failures.  It must be called before the
+// credentials are used.
+func (creds *TLSCredentials) SetLogger(logger Logger) {
+	creds.logger = logger
+}
+
+// current returns the current certificate and pool reloading them first if the files
+// have changed
+func (creds *TLSCredentials) current() (*tls.Certificate, *x509.CertPool) {
+	if creds.conf.ReloadInterval > 0 {
+		creds.mu.RLock()
+		due := time.Since(creds.lastCheck) >= creds.conf.ReloadInterval
+		creds.mu.RUnlock()
+
+		if due && creds.changed() {
+			if err := creds.load(); err != nil {
+				creds.logger.Error("Failed to reload TLS credentials", FieldError, errField(err))
+			}
+		}
+	}
+
+	creds.mu.RLock()
+	defer creds.mu.RUnlock()
+	return creds.cert, creds.pool
+}
+
+// changed returns true if any of the files have been modified since the last load
+func (creds *TLSCredentials) changed() bool {
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+
+	creds.lastCheck = time.Now()
+	for f, mt := range creds.modTimes {
+		st, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !st.ModTime().Equal(mt) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerConfig returns a tls config for servers.  The current certificate and CA pool
+// are used for each new connection.
+func (creds *TLSCredentials) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := creds.current()
+			if cert == nil {
+				return nil, fmt.Errorf("no server certificate")
+			}
+
+			conf := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if creds.conf.ClientAuth {
+				conf.ClientAuth = tls.RequireAndVerifyClientCert
+				conf.ClientCAs = pool
+			}
+			return conf, nil
+		},
+	}
+}
+
+// ClientConfig returns a tls config for clients verifying servers against the current
+// CA pool and presenting the current certificate if any.  Both are read on each
+// handshake so reconnects pick up rotated files.
+func (creds *TLSCredentials) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: creds.conf.ServerName,
+		// The server is verified in VerifyConnection against the pool current at
+		// handshake time rather than a pool fixed in the config
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, pool := creds.current()
+			return verifyServer(cs, pool)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := creds.current()
+			if cert == nil {
+				// No certificate is sent
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+}
+
+// verifyServer verifies the server certificate chain against the pool and the server
+// name as the tls package does when InsecureSkipVerify is not set.  A nil pool uses the
+// system roots.
+func verifyServer(cs tls.ConnectionState, pool *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// ServerOption returns the grpc server option to serve using these credentials
+func (creds *TLSCredentials) ServerOption() grpc.ServerOption {
+	return grpc.Creds(credentials.NewTLS(creds.ServerConfig()))
+}
+
+// dialOption returns the grpc dial option for a new client connection
+func (creds *TLSCredentials) dialOption() grpc.DialOption {
+	return grpc.WithTransportCredentials(credentials.NewTLS(creds.ClientConfig()))
+}
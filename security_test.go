@@ -0,0 +1,296 @@
+package hexaring
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hexaring test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) writeCA(t *testing.T, file string) {
+	writeTestPEM(t, file, "CERTIFICATE", ca.cert.Raw)
+}
+
+// issue writes a certificate and key signed by the ca valid for 127.0.0.1
+func (ca *testCA) issue(t *testing.T, serial int64, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestPEM(t, certFile, "CERTIFICATE", der)
+	writeTestPEM(t, keyFile, "EC PRIVATE KEY", kb)
+}
+
+func writeTestPEM(t *testing.T, file, typ string, b []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecurityConfig_Validate(t *testing.T) {
+	sc := &SecurityConfig{CertFile: "cert.pem"}
+	if err := sc.Validate(); err == nil {
+		t.Fatal("should fail without key")
+	}
+	sc = &SecurityConfig{ClientAuth: true}
+	if err := sc.Validate(); err == nil {
+		t.Fatal("should fail without ca")
+	}
+	if _, err := NewTLSCredentials(&SecurityConfig{CAFile: "does-not-exist.pem"}); err == nil {
+		t.Fatal("should fail with missing file")
+	}
+}
+
+func TestTLSCredentials_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hexaring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ca       = newTestCA(t)
+		caFile   = filepath.Join(dir, "ca.pem")
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+	ca.writeCA(t, caFile)
+	ca.issue(t, 2, certFile, keyFile)
+
+	creds, err := NewTLSCredentials(&SecurityConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CAFile:         caFile,
+		ReloadInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := creds.current()
+	first := cert.Certificate[0]
+
+	ca.issue(t, 3, certFile, keyFile)
+	// Make sure the modification time changes on coarse filesystems
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	<-time.After(5 * time.Millisecond)
+
+	cert, _ = creds.current()
+	if string(cert.Certificate[0]) == string(first) {
+		t.Fatal("certificate should be reloaded")
+	}
+
+	// A bad file keeps the current credentials
+	ioutil.WriteFile(certFile, []byte("garbage"), 0600)
+	future = future.Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	<-time.After(5 * time.Millisecond)
+
+	if c, _ := creds.current(); c != cert {
+		t.Fatal("should keep credentials on reload failure")
+	}
+}
+
+func TestTLSCredentials_ClientReloadCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hexaring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		serverCA = newTestCA(t)
+		otherCA  = newTestCA(t)
+		caFile   = filepath.Join(dir, "ca.pem")
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+	serverCA.writeCA(t, caFile)
+	serverCA.issue(t, 2, certFile, keyFile)
+	server, err := NewTLSCredentials(&SecurityConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server.ServerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// The client trusts another CA
+	clientCAFile := filepath.Join(dir, "client-ca.pem")
+	otherCA.writeCA(t, clientCAFile)
+	client, err := NewTLSCredentials(&SecurityConfig{CAFile: clientCAFile, ReloadInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := client.ClientConfig()
+
+	handshake := func() error {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), conf)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if err = handshake(); err == nil {
+		t.Fatal("should not trust the server")
+	}
+
+	// Rotate the CA without a new config
+	serverCA.writeCA(t, clientCAFile)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(clientCAFile, future, future)
+	<-time.After(5 * time.Millisecond)
+
+	if err = handshake(); err != nil {
+		t.Fatal("should trust the server after the CA is rotated", err)
+	}
+}
+
+func TestNetClient_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hexaring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	ca.writeCA(t, filepath.Join(dir, "ca.pem"))
+	ca.issue(t, 2, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	ca.issue(t, 3, filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"))
+
+	r, err := initTestRing("127.0.0.1:16445")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lookup service on its own mutual TLS server
+	r.conf.Security = &SecurityConfig{
+		CertFile:   filepath.Join(dir, "server.pem"),
+		KeyFile:    filepath.Join(dir, "server.key"),
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		ClientAuth: true,
+	}
+	opts, err := r.ServerOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:16446")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	r.RegisterLookupServer(server)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	creds, err := NewTLSCredentials(&SecurityConfig{
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client.key"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewNetClient(2*time.Second, 10*time.Second, WithTLS(creds))
+	defer client.Shutdown()
+
+	locs, err := client.LookupReplicated("127.0.0.1:16446", testkey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 {
+		t.Fatal("should have 1 location")
+	}
+
+	// No client certificate
+	anon, err := NewTLSCredentials(&SecurityConfig{CAFile: filepath.Join(dir, "ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client2 := NewNetClient(2*time.Second, 10*time.Second, WithTLS(anon))
+	defer client2.Shutdown()
+	if _, err = client2.LookupReplicated("127.0.0.1:16446", testkey, 1); err == nil {
+		t.Fatal("should fail without client certificate")
+	}
+
+	// Plaintext
+	client3 := NewNetClient(2*time.Second, 10*time.Second)
+	defer client3.Shutdown()
+	if _, err = client3.LookupReplicated("127.0.0.1:16446", testkey, 1); err == nil {
+		t.Fatal("should fail without tls")
+	}
+}
@@ -0,0 +1,232 @@
+package hexaring
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// SmartClientConfig contains the options of a SmartClient
+type SmartClientConfig struct {
+	// How often the topology is fetched.  Defaults to 10s
+	RefreshInterval time.Duration
+	// Age after which the topology is considered stale and lookups are made remotely.
+	// Defaults to 30s
+	MaxAge time.Duration
+	// Every nth lookup is also made remotely to verify the local result.  Zero disables
+	// verification
+	VerifyEvery int
+	// Hash function used by the ring.  Defaults to sha1
+	HashFunc func() hash.Hash
+	// Placement strategy used by the ring.  Nil uses the ring's default equidistant
+	// placement with the failure domains and weight key from the fetched topology.  It
+	// must be set when the ring is configured with another placement
+	Placement PlacementStrategy
+}
+
+// DefaultSmartClientConfig returns a sane config
+func DefaultSmartClientConfig() *SmartClientConfig {
+	return &SmartClientConfig{
+		RefreshInterval: 10 * time.Second,
+		MaxAge:          30 * time.Second,
+		VerifyEvery:     100,
+		HashFunc:        sha1.New,
+	}
+}
+
+// topologySnapshot is a fetched topology
+type topologySnapshot struct {
+	lookup SuccessorLookup
+	// Default placement of the ring
+	placement PlacementStrategy
+	fetched   time.Time
+}
+
+// newTopologySnapshot returns the snapshot of a fetched topology
+func newTopologySnapshot(topo *Topology) *topologySnapshot {
+	sortVnodes(topo.Vnodes)
+
+	p := NewEquidistantPlacement(topo.FailureDomains...)
+	p.SetWeightKey(topo.WeightKey)
+	return &topologySnapshot{
+		lookup:    topologyLookup(topo.Vnodes, int(topo.NumSuccessors)),
+		placement: p,
+		fetched:   time.Now(),
+	}
+}
+
+// SmartClient computes replicated lookups locally from a periodically fetched topology
+// saving a hop to a ring member.  Lookups are made remotely when the topology is stale
+// and a remote lookup disagreeing with the local one triggers a refresh.  The config
+// must match the ring's hash function, and its placement if the ring does not use the
+// default one, for results to agree.
+type SmartClient struct {
+	conf   *SmartClientConfig
+	remote *RingClient
+
+	mu   sync.RWMutex
+	snap *topologySnapshot
+
+	lookups    uint64
+	refreshing int32
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// NewSmartClient instantiates a SmartClient fetching the topology and making remote
+// lookups with the RingClient.  The topology is refreshed in the background until
+// Shutdown is called.  Unset or non-positive config values take their defaults.
+func NewSmartClient(conf *SmartClientConfig, remote *RingClient) *SmartClient {
+	// Copied so the defaults are not written to the caller's config
+	c := *conf
+	def := DefaultSmartClientConfig()
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = def.RefreshInterval
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = def.MaxAge
+	}
+	if c.HashFunc == nil {
+		c.HashFunc = def.HashFunc
+	}
+
+	sc := &SmartClient{conf: &c, remote: remote, shutdown: make(chan struct{})}
+	go sc.refreshLoop()
+	return sc
+}
+
+// Refresh fetches the topology
+func (sc *SmartClient) Refresh(ctx context.Context) error {
+	topo, err := sc.remote.TopologyCtx(ctx)
+	if err != nil {
+		return err
+	}
+	if len(topo.Vnodes) == 0 || topo.NumSuccessors < 1 {
+		return fmt.Errorf("invalid topology")
+	}
+
+	snap := newTopologySnapshot(topo)
+
+	sc.mu.Lock()
+	sc.snap = snap
+	sc.mu.Unlock()
+
+	return nil
+}
+
+// refreshAsync refreshes the topology in the background unless a refresh is running
+func (sc *SmartClient) refreshAsync() {
+	if !atomic.CompareAndSwapInt32(&sc.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		sc.Refresh(context.Background())
+		atomic.StoreInt32(&sc.refreshing, 0)
+	}()
+}
+
+func (sc *SmartClient) refreshLoop() {
+	sc.Refresh(context.Background())
+
+	ticker := time.NewTicker(sc.conf.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sc.Refresh(context.Background())
+		case <-sc.shutdown:
+			return
+		}
+	}
+}
+
+// Shutdown stops refreshing the topology.  It is safe to call more than once.
+func (sc *SmartClient) Shutdown() {
+	sc.shutdownOnce.Do(func() { close(sc.shutdown) })
+}
+
+// current returns the topology if it is not stale
+func (sc *SmartClient) current() *topologySnapshot {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	if sc.snap == nil || time.Since(sc.snap.fetched) > sc.conf.MaxAge {
+		return nil
+	}
+	return sc.snap
+}
+
+// LookupReplicated returns the replica locations of a key
+func (sc *SmartClient) LookupReplicated(key []byte, n int) (LocationSet, error) {
+	return sc.LookupReplicatedCtx(context.Background(), key, n)
+}
+
+// LookupReplicatedCtx is the context aware version of LookupReplicated
+func (sc *SmartClient) LookupReplicatedCtx(ctx context.Context, key []byte, n int) (LocationSet, error) {
+	h := sc.conf.HashFunc()
+	h.Write(key)
+	return sc.LookupReplicatedHashCtx(ctx, h.Sum(nil), n)
+}
+
+// LookupReplicatedHash returns the replica locations of a hash
+func (sc *SmartClient) LookupReplicatedHash(hash []byte, n int) (LocationSet, error) {
+	return sc.LookupReplicatedHashCtx(context.Background(), hash, n)
+}
+
+// LookupReplicatedHashCtx is the context aware version of LookupReplicatedHash
+func (sc *SmartClient) LookupReplicatedHashCtx(ctx context.Context, hash []byte, n int) (LocationSet, error) {
+	snap := sc.current()
+	if snap == nil {
+		sc.refreshAsync()
+		return sc.remoteLookup(ctx, hash, n)
+	}
+
+	placement := sc.conf.Placement
+	if placement == nil {
+		placement = snap.placement
+	}
+
+	locs, err := placement.Place(ctx, snap.lookup, hash, n)
+	if err != nil {
+		sc.refreshAsync()
+		return sc.remoteLookup(ctx, hash, n)
+	}
+
+	if v := sc.conf.VerifyEvery; v > 0 && atomic.AddUint64(&sc.lookups, 1)%uint64(v) == 0 {
+		rlocs, err := sc.remoteLookup(ctx, hash, n)
+		if err == nil && !sameLocations(locs, rlocs) {
+			// Our topology is out of date
+			sc.refreshAsync()
+			return rlocs, nil
+		}
+	}
+
+	return locs, nil
+}
+
+func (sc *SmartClient) remoteLookup(ctx context.Context, hash []byte, n int) (LocationSet, error) {
+	locs, err := sc.remote.LookupReplicatedHashCtx(ctx, hash, int32(n))
+	return LocationSet(locs), err
+}
+
+// sameLocations returns true if both sets have the same ids on the same vnodes
+func sameLocations(a, b LocationSet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].ID, b[i].ID) || a[i].Vnode.Host != b[i].Vnode.Host ||
+			!bytes.Equal(a[i].Vnode.Id, b[i].Vnode.Id) {
+			return false
+		}
+	}
+	return true
+}
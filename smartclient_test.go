@@ -0,0 +1,193 @@
+package hexaring
+
+import (
+	"crypto/sha1"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func TestTopologyLookup(t *testing.T) {
+	vns := testRingVnodes("h1", "h2", "h3", "h4")
+	sortVnodes(vns)
+
+	var (
+		local  = topologyLookup(vns, 8)
+		expect = testRingLookup(vns, 8)
+	)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		h := sha1.Sum([]byte(k))
+		l1, _ := local(context.Background(), h[:])
+		l2, _ := expect(context.Background(), h[:])
+		if len(l1) != len(l2) {
+			t.Fatal("length mismatch")
+		}
+		for i := range l1 {
+			if l1[i] != l2[i] {
+				t.Fatal("successor mismatch", k, i)
+			}
+		}
+	}
+
+	if _, err := topologyLookup(nil, 8)(context.Background(), []byte("a")); err == nil {
+		t.Fatal("should fail with empty topology")
+	}
+}
+
+func TestSmartClient_Local(t *testing.T) {
+	vns := testRingVnodes("h1", "h2", "h3", "h4")
+	sortVnodes(vns)
+
+	conf := DefaultSmartClientConfig()
+	conf.VerifyEvery = 0
+	sc := &SmartClient{
+		conf:   conf,
+		remote: testRingClient(),
+		snap:   newTopologySnapshot(&Topology{Vnodes: vns, NumSuccessors: 8}),
+	}
+
+	hash := sha1.Sum(testkey)
+	expect, err := NewEquidistantPlacement().Place(context.Background(), testRingLookup(vns, 8), hash[:], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locs, err := sc.LookupReplicated(testkey, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameLocations(locs, expect) {
+		t.Fatal("local lookup should match placement")
+	}
+
+	// Stale topologies go remote which has no peers
+	sc.snap.fetched = time.Now().Add(-time.Hour)
+	sc.refreshing = 1
+	if _, err = sc.LookupReplicated(testkey, 3); err == nil {
+		t.Fatal("should make a remote lookup")
+	}
+}
+
+func TestSmartClient_FailureDomains(t *testing.T) {
+	vns := testRingVnodes("h1", "h2", "h3", "h4", "h5", "h6")
+	for i, vn := range vns {
+		// Hosts alternate between two zones
+		zone := []byte{'a' + byte(i/4%2)}
+		vn.Meta = chord.Meta{"zone": zone}.MarshalBinary()
+	}
+	sortVnodes(vns)
+
+	conf := DefaultSmartClientConfig()
+	conf.VerifyEvery = 0
+	sc := &SmartClient{
+		conf:   conf,
+		remote: testRingClient(),
+		snap: newTopologySnapshot(&Topology{
+			Vnodes:         vns,
+			NumSuccessors:  8,
+			FailureDomains: []string{"zone"},
+		}),
+	}
+
+	// Placement of a ring configured with the zone failure domain
+	ring := NewEquidistantPlacement("zone")
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		hash := sha1.Sum([]byte(k))
+		expect, err := ring.Place(context.Background(), testRingLookup(vns, 8), hash[:], 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs, err := sc.LookupReplicatedHash(hash[:], 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameLocations(locs, expect) {
+			t.Fatal("local lookup should match the ring placement", k)
+		}
+		if string(vnodeMeta(locs[0].Vnode)["zone"]) == string(vnodeMeta(locs[1].Vnode)["zone"]) {
+			t.Fatal("replicas should be in different zones", k)
+		}
+	}
+}
+
+func TestNewSmartClient(t *testing.T) {
+	conf := &SmartClientConfig{}
+	sc := NewSmartClient(conf, testRingClient())
+	defer sc.Shutdown()
+
+	if sc.conf.RefreshInterval != 10*time.Second || sc.conf.MaxAge != 30*time.Second || sc.conf.HashFunc == nil {
+		t.Fatal("should use defaults", sc.conf)
+	}
+	if conf.RefreshInterval != 0 || conf.HashFunc != nil {
+		t.Fatal("caller config should not be modified")
+	}
+	sc.Shutdown()
+}
+
+func TestSameLocations(t *testing.T) {
+	a := testLocationSet("h1", "h2", "h3")
+	b := copyLocationSet(a)
+	if !sameLocations(a, b) {
+		t.Fatal("should be same")
+	}
+	b[1].ID = []byte("other")
+	if sameLocations(a, b) {
+		t.Fatal("should differ")
+	}
+	if sameLocations(a, a[:2]) {
+		t.Fatal("should differ in length")
+	}
+}
+
+func TestSmartClient(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:16745")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	if _, err = initTestRing("127.0.0.1:16746", "127.0.0.1:16745"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(300 * time.Millisecond)
+
+	client := NewNetClient(2*time.Second, 10*time.Second)
+	defer client.Shutdown()
+
+	topo, err := client.Topology("127.0.0.1:16745")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.Vnodes) != 2*r1.conf.NumVnodes {
+		t.Fatal("wrong vnode count", len(topo.Vnodes))
+	}
+
+	ps := NewInMemPeerStore()
+	ps.AddPeer("127.0.0.1:16745")
+	conf := DefaultSmartClientConfig()
+	conf.VerifyEvery = 1
+	remote, err := NewRingClient(DefaultRingClientConfig(), client, ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := NewSmartClient(conf, remote)
+	defer sc.Shutdown()
+
+	if err = sc.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := r1.LookupReplicated(testkey, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locs, err := sc.LookupReplicated(testkey, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameLocations(locs, expect) {
+		t.Fatal("smart lookup should match the ring")
+	}
+}
@@ -12,6 +12,15 @@ It has these top-level messages:
 	Location
 	LookupRequest
 	LookupResponse
+	LookupBatchRequest
+	LookupResult
+	LookupBatchResponse
+	ScourSectorRequest
+	TopologyRequest
+	Topology
+	Arc
+	MerkleLevelRequest
+	MerkleLevelResponse
 */
 package hexaring
 
@@ -127,10 +136,274 @@ func (m *LookupResponse) GetVnodes() []*chord.Vnode {
 	return nil
 }
 
+type LookupBatchRequest struct {
+	Keys [][]byte `protobuf:"bytes,1,rep,name=Keys,json=keys,proto3" json:"Keys,omitempty"`
+	N    int32    `protobuf:"varint,2,opt,name=N,json=n" json:"N,omitempty"`
+}
+
+func (m *LookupBatchRequest) Reset()                    { *m = LookupBatchRequest{} }
+func (m *LookupBatchRequest) String() string            { return proto.CompactTextString(m) }
+func (*LookupBatchRequest) ProtoMessage()               {}
+func (*LookupBatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *LookupBatchRequest) GetKeys() [][]byte {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *LookupBatchRequest) GetN() int32 {
+	if m != nil {
+		return m.N
+	}
+	return 0
+}
+
+// Result for a single key in a batch
+type LookupResult struct {
+	Locations []*Location    `protobuf:"bytes,1,rep,name=Locations,json=locations" json:"Locations,omitempty"`
+	Vnodes    []*chord.Vnode `protobuf:"bytes,2,rep,name=Vnodes,json=vnodes" json:"Vnodes,omitempty"`
+	// Set if the lookup for the key failed
+	Error string `protobuf:"bytes,3,opt,name=Error,json=error" json:"Error,omitempty"`
+}
+
+func (m *LookupResult) Reset()                    { *m = LookupResult{} }
+func (m *LookupResult) String() string            { return proto.CompactTextString(m) }
+func (*LookupResult) ProtoMessage()               {}
+func (*LookupResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *LookupResult) GetLocations() []*Location {
+	if m != nil {
+		return m.Locations
+	}
+	return nil
+}
+
+func (m *LookupResult) GetVnodes() []*chord.Vnode {
+	if m != nil {
+		return m.Vnodes
+	}
+	return nil
+}
+
+func (m *LookupResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type LookupBatchResponse struct {
+	// One result per key in request order
+	Results []*LookupResult `protobuf:"bytes,1,rep,name=Results,json=results" json:"Results,omitempty"`
+}
+
+func (m *LookupBatchResponse) Reset()                    { *m = LookupBatchResponse{} }
+func (m *LookupBatchResponse) String() string            { return proto.CompactTextString(m) }
+func (*LookupBatchResponse) ProtoMessage()               {}
+func (*LookupBatchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *LookupBatchResponse) GetResults() []*LookupResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type ScourSectorRequest struct {
+	Start []byte `protobuf:"bytes,1,opt,name=Start,json=start,proto3" json:"Start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=End,json=end,proto3" json:"End,omitempty"`
+}
+
+func (m *ScourSectorRequest) Reset()                    { *m = ScourSectorRequest{} }
+func (m *ScourSectorRequest) String() string            { return proto.CompactTextString(m) }
+func (*ScourSectorRequest) ProtoMessage()               {}
+func (*ScourSectorRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ScourSectorRequest) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *ScourSectorRequest) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+type TopologyRequest struct {
+}
+
+func (m *TopologyRequest) Reset()                    { *m = TopologyRequest{} }
+func (m *TopologyRequest) String() string            { return proto.CompactTextString(m) }
+func (*TopologyRequest) ProtoMessage()               {}
+func (*TopologyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+// Snapshot of the ring
+type Topology struct {
+	// All vnodes ordered by id
+	Vnodes []*chord.Vnode `protobuf:"bytes,1,rep,name=Vnodes,json=vnodes" json:"Vnodes,omitempty"`
+	// Number of successors returned by a lookup on the ring
+	NumSuccessors int32 `protobuf:"varint,2,opt,name=NumSuccessors,json=numSuccessors" json:"NumSuccessors,omitempty"`
+	// Arc owned by each vnode in the same order as Vnodes
+	Arcs []*Arc `protobuf:"bytes,3,rep,name=Arcs,json=arcs" json:"Arcs,omitempty"`
+	// Failure domain meta keys of the ring's default placement
+	FailureDomains []string `protobuf:"bytes,4,rep,name=FailureDomains,json=failureDomains" json:"FailureDomains,omitempty"`
+	// Capacity weight meta key of the ring's default placement
+	WeightKey string `protobuf:"bytes,5,opt,name=WeightKey,json=weightKey" json:"WeightKey,omitempty"`
+}
+
+func (m *Topology) Reset()                    { *m = Topology{} }
+func (m *Topology) String() string            { return proto.CompactTextString(m) }
+func (*Topology) ProtoMessage()               {}
+func (*Topology) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Topology) GetVnodes() []*chord.Vnode {
+	if m != nil {
+		return m.Vnodes
+	}
+	return nil
+}
+
+func (m *Topology) GetNumSuccessors() int32 {
+	if m != nil {
+		return m.NumSuccessors
+	}
+	return 0
+}
+
+func (m *Topology) GetArcs() []*Arc {
+	if m != nil {
+		return m.Arcs
+	}
+	return nil
+}
+
+func (m *Topology) GetFailureDomains() []string {
+	if m != nil {
+		return m.FailureDomains
+	}
+	return nil
+}
+
+func (m *Topology) GetWeightKey() string {
+	if m != nil {
+		return m.WeightKey
+	}
+	return ""
+}
+
+// Arc of the ring from Start exclusive to End inclusive
+type Arc struct {
+	Start []byte `protobuf:"bytes,1,opt,name=Start,json=start,proto3" json:"Start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=End,json=end,proto3" json:"End,omitempty"`
+}
+
+func (m *Arc) Reset()                    { *m = Arc{} }
+func (m *Arc) String() string            { return proto.CompactTextString(m) }
+func (*Arc) ProtoMessage()               {}
+func (*Arc) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Arc) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *Arc) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+type MerkleLevelRequest struct {
+	// Arc the tree covers
+	Start []byte `protobuf:"bytes,1,opt,name=Start,json=start,proto3" json:"Start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=End,json=end,proto3" json:"End,omitempty"`
+	// Depth of the tree.  It has 2^Depth leaves
+	Depth int32 `protobuf:"varint,3,opt,name=Depth,json=depth" json:"Depth,omitempty"`
+	// Level of the nodes.  Zero is the root
+	Level int32 `protobuf:"varint,4,opt,name=Level,json=level" json:"Level,omitempty"`
+	// Node indexes within the level
+	Indexes []int32 `protobuf:"varint,5,rep,packed,name=Indexes,json=indexes" json:"Indexes,omitempty"`
+}
+
+func (m *MerkleLevelRequest) Reset()                    { *m = MerkleLevelRequest{} }
+func (m *MerkleLevelRequest) String() string            { return proto.CompactTextString(m) }
+func (*MerkleLevelRequest) ProtoMessage()               {}
+func (*MerkleLevelRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *MerkleLevelRequest) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *MerkleLevelRequest) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+func (m *MerkleLevelRequest) GetDepth() int32 {
+	if m != nil {
+		return m.Depth
+	}
+	return 0
+}
+
+func (m *MerkleLevelRequest) GetLevel() int32 {
+	if m != nil {
+		return m.Level
+	}
+	return 0
+}
+
+func (m *MerkleLevelRequest) GetIndexes() []int32 {
+	if m != nil {
+		return m.Indexes
+	}
+	return nil
+}
+
+type MerkleLevelResponse struct {
+	// One hash per requested index
+	Hashes [][]byte `protobuf:"bytes,1,rep,name=Hashes,json=hashes,proto3" json:"Hashes,omitempty"`
+}
+
+func (m *MerkleLevelResponse) Reset()                    { *m = MerkleLevelResponse{} }
+func (m *MerkleLevelResponse) String() string            { return proto.CompactTextString(m) }
+func (*MerkleLevelResponse) ProtoMessage()               {}
+func (*MerkleLevelResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *MerkleLevelResponse) GetHashes() [][]byte {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Location)(nil), "hexaring.Location")
 	proto.RegisterType((*LookupRequest)(nil), "hexaring.LookupRequest")
 	proto.RegisterType((*LookupResponse)(nil), "hexaring.LookupResponse")
+	proto.RegisterType((*LookupBatchRequest)(nil), "hexaring.LookupBatchRequest")
+	proto.RegisterType((*LookupResult)(nil), "hexaring.LookupResult")
+	proto.RegisterType((*LookupBatchResponse)(nil), "hexaring.LookupBatchResponse")
+	proto.RegisterType((*ScourSectorRequest)(nil), "hexaring.ScourSectorRequest")
+	proto.RegisterType((*TopologyRequest)(nil), "hexaring.TopologyRequest")
+	proto.RegisterType((*Topology)(nil), "hexaring.Topology")
+	proto.RegisterType((*Arc)(nil), "hexaring.Arc")
+	proto.RegisterType((*MerkleLevelRequest)(nil), "hexaring.MerkleLevelRequest")
+	proto.RegisterType((*MerkleLevelResponse)(nil), "hexaring.MerkleLevelResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -148,6 +421,14 @@ type LookupRPCClient interface {
 	LookupHashRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
 	LookupReplicatedRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
 	LookupReplicatedHashRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	LookupHashBatchRPC(ctx context.Context, in *LookupBatchRequest, opts ...grpc.CallOption) (*LookupBatchResponse, error)
+	LookupReplicatedBatchRPC(ctx context.Context, in *LookupBatchRequest, opts ...grpc.CallOption) (*LookupBatchResponse, error)
+	// Scour streams vnodes in visit order with hosts de-duplicated
+	ScourReplicatedKeyRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (LookupRPC_ScourReplicatedKeyRPCClient, error)
+	ScourReplicaRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (LookupRPC_ScourReplicaRPCClient, error)
+	ScourSectorRPC(ctx context.Context, in *ScourSectorRequest, opts ...grpc.CallOption) (LookupRPC_ScourSectorRPCClient, error)
+	// Topology returns a snapshot of all vnodes in the ring
+	TopologyRPC(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*Topology, error)
 }
 
 type lookupRPCClient struct {
@@ -194,6 +475,129 @@ func (c *lookupRPCClient) LookupReplicatedHashRPC(ctx context.Context, in *Looku
 	return out, nil
 }
 
+func (c *lookupRPCClient) LookupHashBatchRPC(ctx context.Context, in *LookupBatchRequest, opts ...grpc.CallOption) (*LookupBatchResponse, error) {
+	out := new(LookupBatchResponse)
+	err := grpc.Invoke(ctx, "/hexaring.LookupRPC/LookupHashBatchRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lookupRPCClient) LookupReplicatedBatchRPC(ctx context.Context, in *LookupBatchRequest, opts ...grpc.CallOption) (*LookupBatchResponse, error) {
+	out := new(LookupBatchResponse)
+	err := grpc.Invoke(ctx, "/hexaring.LookupRPC/LookupReplicatedBatchRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lookupRPCClient) ScourReplicatedKeyRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (LookupRPC_ScourReplicatedKeyRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_LookupRPC_serviceDesc.Streams[0], c.cc, "/hexaring.LookupRPC/ScourReplicatedKeyRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lookupRPCScourReplicatedKeyRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LookupRPC_ScourReplicatedKeyRPCClient interface {
+	Recv() (*chord.Vnode, error)
+	grpc.ClientStream
+}
+
+type lookupRPCScourReplicatedKeyRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *lookupRPCScourReplicatedKeyRPCClient) Recv() (*chord.Vnode, error) {
+	m := new(chord.Vnode)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lookupRPCClient) ScourReplicaRPC(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (LookupRPC_ScourReplicaRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_LookupRPC_serviceDesc.Streams[1], c.cc, "/hexaring.LookupRPC/ScourReplicaRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lookupRPCScourReplicaRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LookupRPC_ScourReplicaRPCClient interface {
+	Recv() (*chord.Vnode, error)
+	grpc.ClientStream
+}
+
+type lookupRPCScourReplicaRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *lookupRPCScourReplicaRPCClient) Recv() (*chord.Vnode, error) {
+	m := new(chord.Vnode)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lookupRPCClient) ScourSectorRPC(ctx context.Context, in *ScourSectorRequest, opts ...grpc.CallOption) (LookupRPC_ScourSectorRPCClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_LookupRPC_serviceDesc.Streams[2], c.cc, "/hexaring.LookupRPC/ScourSectorRPC", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lookupRPCScourSectorRPCClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LookupRPC_ScourSectorRPCClient interface {
+	Recv() (*chord.Vnode, error)
+	grpc.ClientStream
+}
+
+type lookupRPCScourSectorRPCClient struct {
+	grpc.ClientStream
+}
+
+func (x *lookupRPCScourSectorRPCClient) Recv() (*chord.Vnode, error) {
+	m := new(chord.Vnode)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lookupRPCClient) TopologyRPC(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*Topology, error) {
+	out := new(Topology)
+	err := grpc.Invoke(ctx, "/hexaring.LookupRPC/TopologyRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for LookupRPC service
 
 type LookupRPCServer interface {
@@ -201,6 +605,14 @@ type LookupRPCServer interface {
 	LookupHashRPC(context.Context, *LookupRequest) (*LookupResponse, error)
 	LookupReplicatedRPC(context.Context, *LookupRequest) (*LookupResponse, error)
 	LookupReplicatedHashRPC(context.Context, *LookupRequest) (*LookupResponse, error)
+	LookupHashBatchRPC(context.Context, *LookupBatchRequest) (*LookupBatchResponse, error)
+	LookupReplicatedBatchRPC(context.Context, *LookupBatchRequest) (*LookupBatchResponse, error)
+	// Scour streams vnodes in visit order with hosts de-duplicated
+	ScourReplicatedKeyRPC(*LookupRequest, LookupRPC_ScourReplicatedKeyRPCServer) error
+	ScourReplicaRPC(*LookupRequest, LookupRPC_ScourReplicaRPCServer) error
+	ScourSectorRPC(*ScourSectorRequest, LookupRPC_ScourSectorRPCServer) error
+	// Topology returns a snapshot of all vnodes in the ring
+	TopologyRPC(context.Context, *TopologyRequest) (*Topology, error)
 }
 
 func RegisterLookupRPCServer(s *grpc.Server, srv LookupRPCServer) {
@@ -279,6 +691,123 @@ func _LookupRPC_LookupReplicatedHashRPC_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LookupRPC_LookupHashBatchRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LookupRPCServer).LookupHashBatchRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hexaring.LookupRPC/LookupHashBatchRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LookupRPCServer).LookupHashBatchRPC(ctx, req.(*LookupBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LookupRPC_LookupReplicatedBatchRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LookupRPCServer).LookupReplicatedBatchRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hexaring.LookupRPC/LookupReplicatedBatchRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LookupRPCServer).LookupReplicatedBatchRPC(ctx, req.(*LookupBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LookupRPC_ScourReplicatedKeyRPC_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LookupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LookupRPCServer).ScourReplicatedKeyRPC(m, &lookupRPCScourReplicatedKeyRPCServer{stream})
+}
+
+type LookupRPC_ScourReplicatedKeyRPCServer interface {
+	Send(*chord.Vnode) error
+	grpc.ServerStream
+}
+
+type lookupRPCScourReplicatedKeyRPCServer struct {
+	grpc.ServerStream
+}
+
+func (x *lookupRPCScourReplicatedKeyRPCServer) Send(m *chord.Vnode) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LookupRPC_ScourReplicaRPC_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LookupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LookupRPCServer).ScourReplicaRPC(m, &lookupRPCScourReplicaRPCServer{stream})
+}
+
+type LookupRPC_ScourReplicaRPCServer interface {
+	Send(*chord.Vnode) error
+	grpc.ServerStream
+}
+
+type lookupRPCScourReplicaRPCServer struct {
+	grpc.ServerStream
+}
+
+func (x *lookupRPCScourReplicaRPCServer) Send(m *chord.Vnode) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LookupRPC_ScourSectorRPC_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScourSectorRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LookupRPCServer).ScourSectorRPC(m, &lookupRPCScourSectorRPCServer{stream})
+}
+
+type LookupRPC_ScourSectorRPCServer interface {
+	Send(*chord.Vnode) error
+	grpc.ServerStream
+}
+
+type lookupRPCScourSectorRPCServer struct {
+	grpc.ServerStream
+}
+
+func (x *lookupRPCScourSectorRPCServer) Send(m *chord.Vnode) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LookupRPC_TopologyRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopologyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LookupRPCServer).TopologyRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hexaring.LookupRPC/TopologyRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LookupRPCServer).TopologyRPC(ctx, req.(*TopologyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _LookupRPC_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "hexaring.LookupRPC",
 	HandlerType: (*LookupRPCServer)(nil),
@@ -299,6 +828,100 @@ var _LookupRPC_serviceDesc = grpc.ServiceDesc{
 			MethodName: "LookupReplicatedHashRPC",
 			Handler:    _LookupRPC_LookupReplicatedHashRPC_Handler,
 		},
+		{
+			MethodName: "LookupHashBatchRPC",
+			Handler:    _LookupRPC_LookupHashBatchRPC_Handler,
+		},
+		{
+			MethodName: "LookupReplicatedBatchRPC",
+			Handler:    _LookupRPC_LookupReplicatedBatchRPC_Handler,
+		},
+		{
+			MethodName: "TopologyRPC",
+			Handler:    _LookupRPC_TopologyRPC_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ScourReplicatedKeyRPC",
+			Handler:       _LookupRPC_ScourReplicatedKeyRPC_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScourReplicaRPC",
+			Handler:       _LookupRPC_ScourReplicaRPC_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScourSectorRPC",
+			Handler:       _LookupRPC_ScourSectorRPC_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "structs.proto",
+}
+
+// Client API for MerkleRPC service
+
+type MerkleRPCClient interface {
+	// Returns the hashes of the requested nodes at a level of the tree over an arc
+	MerkleLevelRPC(ctx context.Context, in *MerkleLevelRequest, opts ...grpc.CallOption) (*MerkleLevelResponse, error)
+}
+
+type merkleRPCClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMerkleRPCClient(cc *grpc.ClientConn) MerkleRPCClient {
+	return &merkleRPCClient{cc}
+}
+
+func (c *merkleRPCClient) MerkleLevelRPC(ctx context.Context, in *MerkleLevelRequest, opts ...grpc.CallOption) (*MerkleLevelResponse, error) {
+	out := new(MerkleLevelResponse)
+	err := grpc.Invoke(ctx, "/hexaring.MerkleRPC/MerkleLevelRPC", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for MerkleRPC service
+
+type MerkleRPCServer interface {
+	// Returns the hashes of the requested nodes at a level of the tree over an arc
+	MerkleLevelRPC(context.Context, *MerkleLevelRequest) (*MerkleLevelResponse, error)
+}
+
+func RegisterMerkleRPCServer(s *grpc.Server, srv MerkleRPCServer) {
+	s.RegisterService(&_MerkleRPC_serviceDesc, srv)
+}
+
+func _MerkleRPC_MerkleLevelRPC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MerkleLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleRPCServer).MerkleLevelRPC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hexaring.MerkleRPC/MerkleLevelRPC",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleRPCServer).MerkleLevelRPC(ctx, req.(*MerkleLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _MerkleRPC_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "hexaring.MerkleRPC",
+	HandlerType: (*MerkleRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "MerkleLevelRPC",
+			Handler:    _MerkleRPC_MerkleLevelRPC_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "structs.proto",
@@ -307,26 +930,51 @@ var _LookupRPC_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("structs.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 325 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x92, 0x4f, 0x4b, 0xf3, 0x40,
-	0x10, 0xc6, 0xdf, 0x4d, 0xde, 0x94, 0x74, 0xfa, 0x07, 0x59, 0x85, 0x2e, 0x3d, 0x85, 0x20, 0x98,
-	0x8b, 0x89, 0xd4, 0x2f, 0x20, 0xd8, 0x83, 0xd5, 0x52, 0x4a, 0x0e, 0xde, 0xd3, 0x64, 0x69, 0x96,
-	0xd6, 0x9d, 0x98, 0xdd, 0x48, 0xfb, 0xa9, 0xfc, 0x8a, 0x92, 0x6d, 0x17, 0xaa, 0xde, 0xea, 0x69,
-	0x99, 0x79, 0x66, 0x9e, 0xe7, 0x37, 0xb0, 0x30, 0x50, 0xba, 0x6e, 0x72, 0xad, 0xe2, 0xaa, 0x46,
-	0x8d, 0xd4, 0x2f, 0xf9, 0x2e, 0xab, 0x85, 0x5c, 0x8f, 0x6f, 0xd6, 0x42, 0x97, 0xcd, 0x2a, 0xce,
-	0xf1, 0x2d, 0x69, 0x9b, 0xab, 0x2d, 0xe6, 0x9b, 0x64, 0x8d, 0xb7, 0x79, 0x89, 0x75, 0x91, 0x48,
-	0xae, 0x0f, 0x2b, 0x61, 0x05, 0xfe, 0x1c, 0xf3, 0x4c, 0x0b, 0x94, 0x74, 0x08, 0xce, 0x6c, 0xca,
-	0x48, 0x40, 0xa2, 0x7e, 0xea, 0x88, 0x29, 0x1d, 0x83, 0xbf, 0xac, 0x05, 0xd6, 0x42, 0xef, 0x99,
-	0x13, 0x90, 0xc8, 0x4b, 0xfd, 0xea, 0x58, 0xd3, 0x2b, 0xf0, 0x66, 0xb2, 0xe0, 0x3b, 0xe6, 0x1a,
-	0xc1, 0x13, 0x6d, 0x41, 0x43, 0xf0, 0x5e, 0x25, 0x16, 0x9c, 0xfd, 0x0f, 0x48, 0xd4, 0x9b, 0xf4,
-	0x63, 0x13, 0x17, 0x9b, 0x5e, 0xea, 0x7d, 0xb4, 0x4f, 0x98, 0xc0, 0x60, 0x8e, 0xb8, 0x69, 0xaa,
-	0x94, 0xbf, 0x37, 0x5c, 0x69, 0x7a, 0x01, 0xee, 0x0b, 0xdf, 0x1f, 0x73, 0xdd, 0x0d, 0xdf, 0xd3,
-	0x3e, 0x90, 0xc5, 0x31, 0x91, 0xc8, 0xb0, 0x84, 0xa1, 0x5d, 0x50, 0x15, 0x4a, 0xc5, 0xe9, 0x1d,
-	0x74, 0x2d, 0xb4, 0x62, 0x24, 0x70, 0xa3, 0xde, 0x84, 0xc6, 0xf6, 0xf6, 0xd8, 0x4a, 0x69, 0x77,
-	0x6b, 0x87, 0xe8, 0x35, 0x74, 0x0c, 0x84, 0x62, 0x8e, 0x19, 0xff, 0x4e, 0xd6, 0x31, 0x64, 0x6a,
-	0xf2, 0xe9, 0xb4, 0xc6, 0x26, 0x6a, 0xf9, 0x48, 0x1f, 0x4e, 0x8b, 0xd1, 0xa9, 0xff, 0x09, 0xfd,
-	0x98, 0xfd, 0x16, 0x0e, 0x94, 0xe1, 0x3f, 0x3a, 0xb5, 0xa7, 0x3e, 0x65, 0xaa, 0x3c, 0xdb, 0xe5,
-	0x19, 0x2e, 0x6d, 0xaf, 0xda, 0x8a, 0x3c, 0xd3, 0xbc, 0x38, 0xdb, 0x6b, 0x01, 0xa3, 0x9f, 0x5e,
-	0x7f, 0x61, 0x5b, 0x75, 0xcc, 0x2f, 0xba, 0xff, 0x0a, 0x00, 0x00, 0xff, 0xff, 0x50, 0x45, 0x33,
-	0x9d, 0x89, 0x02, 0x00, 0x00,
+	// 732 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0x5f, 0x6f, 0xda, 0x48,
+	0x10, 0x8f, 0x31, 0x06, 0x3c, 0x01, 0x72, 0xb7, 0xc9, 0x25, 0x3e, 0x94, 0x48, 0x9c, 0x15, 0xdd,
+	0xf1, 0x12, 0x88, 0x38, 0xe9, 0x9e, 0xa2, 0x53, 0xfe, 0x90, 0xbb, 0xa6, 0x49, 0x53, 0x64, 0xaa,
+	0x56, 0x95, 0xfa, 0xe2, 0x98, 0x2d, 0xb6, 0x70, 0xbc, 0xee, 0xee, 0x3a, 0x0d, 0x52, 0x9f, 0xfa,
+	0xc5, 0xfa, 0x61, 0xfa, 0x45, 0xaa, 0x5d, 0x7b, 0xc1, 0x40, 0x13, 0x35, 0x69, 0xfb, 0x84, 0x66,
+	0x66, 0xe7, 0x37, 0xbf, 0x19, 0xcf, 0x6f, 0x80, 0x1a, 0xe3, 0x34, 0xf1, 0x38, 0x6b, 0xc7, 0x94,
+	0x70, 0x82, 0x2a, 0x3e, 0xbe, 0x75, 0x69, 0x10, 0x8d, 0x1a, 0x7f, 0x8d, 0x02, 0xee, 0x27, 0x57,
+	0x6d, 0x8f, 0x5c, 0x77, 0x84, 0xf3, 0x2a, 0x24, 0xde, 0xb8, 0x33, 0x22, 0x7b, 0x9e, 0x4f, 0xe8,
+	0xb0, 0x13, 0x61, 0x9e, 0xa6, 0xd8, 0x31, 0x54, 0x2e, 0x88, 0xe7, 0xf2, 0x80, 0x44, 0xa8, 0x0e,
+	0x85, 0xb3, 0x9e, 0xa5, 0x35, 0xb5, 0x56, 0xd5, 0x29, 0x04, 0x3d, 0xd4, 0x80, 0x4a, 0x9f, 0x06,
+	0x84, 0x06, 0x7c, 0x62, 0x15, 0x9a, 0x5a, 0xcb, 0x70, 0x2a, 0x71, 0x66, 0xa3, 0x0d, 0x30, 0xce,
+	0xa2, 0x21, 0xbe, 0xb5, 0x74, 0x19, 0x30, 0x02, 0x61, 0x20, 0x1b, 0x8c, 0x97, 0x11, 0x19, 0x62,
+	0xab, 0xd8, 0xd4, 0x5a, 0xab, 0xdd, 0x6a, 0x5b, 0x96, 0x6b, 0x4b, 0x9f, 0x63, 0xdc, 0x88, 0x1f,
+	0xbb, 0x03, 0xb5, 0x0b, 0x42, 0xc6, 0x49, 0xec, 0xe0, 0x77, 0x09, 0x66, 0x1c, 0xfd, 0x02, 0xfa,
+	0x39, 0x9e, 0x64, 0x75, 0xf5, 0x31, 0x9e, 0xa0, 0x2a, 0x68, 0x97, 0x59, 0x45, 0x2d, 0xb2, 0x7d,
+	0xa8, 0xab, 0x04, 0x16, 0x93, 0x88, 0x61, 0xb4, 0x0f, 0xa6, 0x22, 0xcd, 0x2c, 0xad, 0xa9, 0xb7,
+	0x56, 0xbb, 0xa8, 0xad, 0x7a, 0x6f, 0xab, 0x90, 0x63, 0x86, 0xea, 0x11, 0xda, 0x85, 0x92, 0x24,
+	0xc1, 0xac, 0x42, 0x53, 0x5f, 0x62, 0x56, 0x92, 0xcc, 0x98, 0xfd, 0x0f, 0xa0, 0xb4, 0xd2, 0xb1,
+	0xcb, 0x3d, 0x5f, 0xf1, 0x43, 0x50, 0x3c, 0xc7, 0x93, 0xb4, 0x50, 0xd5, 0x29, 0x8e, 0xf1, 0x84,
+	0x2d, 0x30, 0xfc, 0x00, 0xd5, 0x29, 0xc3, 0x24, 0xe4, 0x3f, 0x8b, 0x9f, 0x18, 0xfa, 0x29, 0xa5,
+	0x84, 0xca, 0xa1, 0x9b, 0x8e, 0x81, 0x85, 0x61, 0xff, 0x0f, 0xeb, 0x73, 0xac, 0xa7, 0x43, 0x2a,
+	0xa7, 0x74, 0x14, 0x85, 0xcd, 0x3c, 0x85, 0x19, 0x5b, 0xa7, 0x4c, 0xd3, 0x67, 0xf6, 0x01, 0xa0,
+	0x81, 0x47, 0x12, 0x3a, 0xc0, 0x1e, 0x27, 0x54, 0xb5, 0xbf, 0x01, 0xc6, 0x80, 0xbb, 0x94, 0x67,
+	0x1f, 0xc8, 0x60, 0xc2, 0x10, 0x1f, 0xed, 0x34, 0x1a, 0xca, 0x11, 0x54, 0x1d, 0x1d, 0x47, 0x43,
+	0xfb, 0x57, 0x58, 0x7b, 0x41, 0x62, 0x12, 0x92, 0xd1, 0x24, 0x4b, 0xb5, 0x3f, 0x69, 0x50, 0x51,
+	0xbe, 0x5c, 0x8b, 0xda, 0x3d, 0x2d, 0xee, 0x42, 0xed, 0x32, 0xb9, 0x1e, 0x24, 0x9e, 0x87, 0x19,
+	0x23, 0x94, 0x65, 0x43, 0xae, 0x45, 0x79, 0x27, 0xfa, 0x03, 0x8a, 0x47, 0xd4, 0x63, 0x96, 0x2e,
+	0x91, 0x6a, 0xb3, 0xc6, 0x8e, 0xa8, 0xe7, 0x14, 0x5d, 0xea, 0x31, 0xf4, 0x27, 0xd4, 0xff, 0x73,
+	0x83, 0x30, 0xa1, 0xb8, 0x47, 0xae, 0xdd, 0x20, 0x62, 0x56, 0xb1, 0xa9, 0xb7, 0x4c, 0xa7, 0xfe,
+	0x76, 0xce, 0x8b, 0xb6, 0xc1, 0x7c, 0x85, 0x83, 0x91, 0xcf, 0xc5, 0x0e, 0x1a, 0x72, 0xae, 0xe6,
+	0x7b, 0xe5, 0xb0, 0xf7, 0x40, 0x3f, 0xa2, 0xde, 0x37, 0xcf, 0xe0, 0xa3, 0x06, 0xe8, 0x19, 0xa6,
+	0xe3, 0x10, 0x5f, 0xe0, 0x1b, 0x1c, 0x3e, 0x70, 0x84, 0xe2, 0x5d, 0x0f, 0xc7, 0xdc, 0x57, 0xa2,
+	0x1a, 0x0a, 0x43, 0x78, 0x25, 0x9a, 0x14, 0x95, 0xe1, 0x18, 0xa1, 0x30, 0x90, 0x05, 0x65, 0x29,
+	0x40, 0xcc, 0x2c, 0xa3, 0xa9, 0xb7, 0x0c, 0xa7, 0x1c, 0xa4, 0xa6, 0xbd, 0x07, 0xeb, 0x73, 0x1c,
+	0xb2, 0x7d, 0xd8, 0x84, 0xd2, 0x13, 0x97, 0xf9, 0x58, 0x2d, 0x72, 0xc9, 0x97, 0x56, 0xf7, 0xb3,
+	0x01, 0x66, 0xb6, 0x0f, 0xfd, 0x13, 0x74, 0x98, 0x37, 0xb6, 0x96, 0x37, 0x46, 0x36, 0xd4, 0xb0,
+	0x96, 0x03, 0x69, 0x15, 0x7b, 0x05, 0xf5, 0x94, 0xbe, 0x45, 0xb5, 0x47, 0xa3, 0x3c, 0x55, 0x4b,
+	0xed, 0xe0, 0x38, 0x0c, 0x3c, 0x97, 0xe3, 0xe1, 0xa3, 0xb1, 0x2e, 0x61, 0x6b, 0x11, 0xeb, 0xbb,
+	0xb8, 0x0d, 0xd4, 0x99, 0x10, 0x28, 0xa9, 0xe8, 0xfa, 0x27, 0x68, 0x7b, 0x31, 0x23, 0x7f, 0x44,
+	0x1a, 0x3b, 0x77, 0x44, 0xa7, 0xa0, 0xaf, 0xc1, 0x5a, 0x24, 0xf9, 0xa3, 0xa0, 0x8f, 0xe1, 0x37,
+	0xa9, 0xeb, 0x19, 0xf2, 0x39, 0x9e, 0xdc, 0xdb, 0xfd, 0x9c, 0x36, 0xed, 0x95, 0x7d, 0x0d, 0x1d,
+	0xc0, 0x5a, 0x1e, 0xe3, 0x81, 0xd9, 0x87, 0x50, 0xcf, 0x5f, 0x96, 0xf9, 0x96, 0x96, 0x6f, 0xce,
+	0x57, 0x10, 0xfe, 0x85, 0xd5, 0xe9, 0x75, 0xe9, 0x9f, 0xa0, 0xdf, 0x67, 0xe9, 0x0b, 0x47, 0xa7,
+	0x81, 0x96, 0x43, 0xf6, 0x4a, 0xf7, 0x0d, 0x98, 0xa9, 0x28, 0x44, 0xf6, 0x73, 0xa8, 0xe7, 0x15,
+	0x32, 0x4f, 0x67, 0x59, 0xbf, 0x8d, 0x9d, 0x3b, 0xa2, 0x6a, 0xc2, 0x57, 0x25, 0xf9, 0x67, 0xfa,
+	0xf7, 0x97, 0x01, 0x00, 0x31, 0xb5, 0xb8, 0x8e, 0x90, 0x07, 0x00, 0x00,
 }
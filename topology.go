@@ -0,0 +1,204 @@
+package hexaring
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+// maxWalkLookups bounds the lookups of a ring walk in case the ring changes underneath
+const maxWalkLookups = 1 << 16
+
+// Topology returns an ordered snapshot of all vnodes in the ring and the arc each one
+// owns.  It walks the successor chain so the snapshot may be inconsistent if the ring
+// changes during the walk.
+func (r *Ring) Topology() (*Topology, error) {
+	return r.TopologyCtx(context.Background())
+}
+
+// TopologyCtx is the context aware version of Topology
+func (r *Ring) TopologyCtx(ctx context.Context) (*Topology, error) {
+	vns, err := r.walkRing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topo := newTopology(vns, r.conf.NumSuccessors)
+	topo.FailureDomains = r.conf.FailureDomains
+	topo.WeightKey = r.conf.WeightKey
+	return topo, nil
+}
+
+// newTopology returns the topology of the vnodes ordered by id.  Each vnode owns the arc
+// from its predecessor exclusive to itself inclusive.
+func newTopology(vns []*chord.Vnode, numSuccessors int) *Topology {
+	topo := &Topology{
+		Vnodes:        vns,
+		NumSuccessors: int32(numSuccessors),
+		Arcs:          make([]*Arc, len(vns)),
+	}
+	for i, vn := range vns {
+		pred := vns[(i+len(vns)-1)%len(vns)]
+		topo.Arcs[i] = &Arc{Start: pred.Id, End: vn.Id}
+	}
+	return topo
+}
+
+// MarshalBinary returns the protobuf encoding of the topology
+func (topo *Topology) MarshalBinary() ([]byte, error) {
+	return proto.Marshal(topo)
+}
+
+// UnmarshalBinary decodes a protobuf encoded topology
+func (topo *Topology) UnmarshalBinary(b []byte) error {
+	return proto.Unmarshal(b, topo)
+}
+
+type jsonArc struct {
+	Start string
+	End   string
+}
+
+type jsonTopologyVnode struct {
+	ID   string
+	Host string
+	Meta map[string]string
+	Arc  *jsonArc
+}
+
+// MarshalJSON is a custom Topology json marshaller using hex ids and decoded meta
+func (topo Topology) MarshalJSON() ([]byte, error) {
+	vns := make([]jsonTopologyVnode, len(topo.Vnodes))
+	for i, vn := range topo.Vnodes {
+		meta := map[string]string{}
+		for k, v := range vnodeMeta(vn) {
+			meta[k] = string(v)
+		}
+
+		vns[i] = jsonTopologyVnode{ID: hex.EncodeToString(vn.Id), Host: vn.Host, Meta: meta}
+		if i < len(topo.Arcs) {
+			vns[i].Arc = &jsonArc{
+				Start: hex.EncodeToString(topo.Arcs[i].Start),
+				End:   hex.EncodeToString(topo.Arcs[i].End),
+			}
+		}
+	}
+
+	return json.Marshal(struct {
+		NumSuccessors int32
+		Vnodes        []jsonTopologyVnode
+	}{topo.NumSuccessors, vns})
+}
+
+// WriteDOT writes the topology as a Graphviz digraph.  Vnodes are grouped by host and
+// each vnode points to its successor.
+func (topo *Topology) WriteDOT(w io.Writer) error {
+	var (
+		bw     = bufio.NewWriter(w)
+		hosts  []string
+		byHost = map[string][]*chord.Vnode{}
+	)
+	for _, vn := range topo.Vnodes {
+		if _, ok := byHost[vn.Host]; !ok {
+			hosts = append(hosts, vn.Host)
+		}
+		byHost[vn.Host] = append(byHost[vn.Host], vn)
+	}
+
+	fmt.Fprintln(bw, "digraph ring {")
+	fmt.Fprintln(bw, "  layout=circo;")
+	for i, host := range hosts {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "    label=%s;\n", strconv.Quote(host))
+		for _, vn := range byHost[host] {
+			id := hex.EncodeToString(vn.Id)
+			label := id
+			if len(label) > 8 {
+				label = label[:8]
+			}
+			fmt.Fprintf(bw, "    %s [label=%s];\n", strconv.Quote(id), strconv.Quote(label))
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+	for i, vn := range topo.Vnodes {
+		succ := topo.Vnodes[(i+1)%len(topo.Vnodes)]
+		fmt.Fprintf(bw, "  %s -> %s;\n", strconv.Quote(hex.EncodeToString(vn.Id)),
+			strconv.Quote(hex.EncodeToString(succ.Id)))
+	}
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+// walkRing returns every vnode in the ring ordered by id.  It follows the successor
+// lists starting from the beginning of the hash space until it wraps around.
+func (r *Ring) walkRing(ctx context.Context) ([]*chord.Vnode, error) {
+	var (
+		cursor = make([]byte, r.conf.HashFunc().Size())
+		seen   = map[string]bool{}
+		out    []*chord.Vnode
+	)
+
+	for i := 0; i < maxWalkLookups; i++ {
+		vns, err := r.lookupHash(ctx, r.conf.NumSuccessors, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			added   int
+			wrapped bool
+		)
+		for _, vn := range vns {
+			if seen[string(vn.Id)] {
+				if bytes.Equal(vn.Id, cursor) {
+					// The vnode we continued from
+					continue
+				}
+				wrapped = true
+				break
+			}
+			seen[string(vn.Id)] = true
+			out = append(out, vn)
+			added++
+		}
+
+		if wrapped || added == 0 {
+			sortVnodes(out)
+			return out, nil
+		}
+		cursor = out[len(out)-1].Id
+	}
+
+	return nil, fmt.Errorf("ring walk did not complete")
+}
+
+func sortVnodes(vns []*chord.Vnode) {
+	sort.Slice(vns, func(i, j int) bool { return bytes.Compare(vns[i].Id, vns[j].Id) < 0 })
+}
+
+// topologyLookup returns a SuccessorLookup over the vnodes of a topology returning n
+// successors per hash.  The vnodes must be ordered by id.
+func topologyLookup(vns []*chord.Vnode, n int) SuccessorLookup {
+	return func(ctx context.Context, hash []byte) ([]*chord.Vnode, error) {
+		if len(vns) == 0 {
+			return nil, fmt.Errorf("empty topology")
+		}
+
+		i := sort.Search(len(vns), func(i int) bool { return bytes.Compare(vns[i].Id, hash) >= 0 })
+		out := make([]*chord.Vnode, 0, n)
+		for j := 0; j < n && j < len(vns); j++ {
+			out = append(out, vns[(i+j)%len(vns)])
+		}
+		return out, nil
+	}
+}
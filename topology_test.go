@@ -0,0 +1,112 @@
+package hexaring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	chord "github.com/hexablock/go-chord"
+)
+
+func TestNewTopology(t *testing.T) {
+	vns := testRingVnodes("h1", "h2")
+	sortVnodes(vns)
+	topo := newTopology(vns, 4)
+
+	if len(topo.Arcs) != len(vns) {
+		t.Fatal("should have an arc per vnode")
+	}
+	for i, arc := range topo.Arcs {
+		if !bytes.Equal(arc.End, vns[i].Id) {
+			t.Fatal("arc should end at the vnode")
+		}
+		next := topo.Arcs[(i+1)%len(vns)]
+		if !bytes.Equal(next.Start, arc.End) {
+			t.Fatal("arcs should be contiguous")
+		}
+	}
+}
+
+func TestTopology_Export(t *testing.T) {
+	vns := []*chord.Vnode{testVnode("h1", "a", "r1"), testVnode("h2", "b", "r1")}
+	sortVnodes(vns)
+	topo := newTopology(vns, 2)
+
+	b, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		NumSuccessors int32
+		Vnodes        []struct {
+			ID   string
+			Host string
+			Meta map[string]string
+			Arc  struct{ Start, End string }
+		}
+	}
+	if err = json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.NumSuccessors != 2 || len(out.Vnodes) != 2 {
+		t.Fatal("wrong json", string(b))
+	}
+	for i, vn := range out.Vnodes {
+		if vn.ID != hex.EncodeToString(topo.Vnodes[i].Id) || vn.Arc.End != vn.ID {
+			t.Fatal("ids should be hex", string(b))
+		}
+		if vn.Meta["zone"] == "" {
+			t.Fatal("meta should be decoded", string(b))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = topo.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph ring {") || strings.Count(dot, "->") != 2 ||
+		strings.Count(dot, "subgraph cluster_") != 2 {
+		t.Fatal("bad dot", dot)
+	}
+}
+
+func TestRing_Topology(t *testing.T) {
+	r1, err := initTestRing("127.0.0.1:16845")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	if _, err = initTestRing("127.0.0.1:16846", "127.0.0.1:16845"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(300 * time.Millisecond)
+
+	topo, err := r1.Topology()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.Vnodes) != 2*r1.conf.NumVnodes {
+		t.Fatal("wrong vnode count", len(topo.Vnodes))
+	}
+	for i := 1; i < len(topo.Vnodes); i++ {
+		if bytes.Compare(topo.Vnodes[i-1].Id, topo.Vnodes[i].Id) >= 0 {
+			t.Fatal("vnodes should be ordered")
+		}
+	}
+
+	b, err := topo.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topo2 Topology
+	if err = topo2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if len(topo2.Vnodes) != len(topo.Vnodes) || len(topo2.Arcs) != len(topo.Arcs) {
+		t.Fatal("protobuf round trip failed")
+	}
+}
@@ -0,0 +1,266 @@
+package hexaring
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TextMapCarrier carries trace context across process boundaries.  It has the same
+// methods as the OpenTelemetry propagation.TextMapCarrier.
+type TextMapCarrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// Span is a timed operation within a trace
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer creates spans and propagates their context.  An adapter over an OpenTelemetry
+// trace.Tracer and propagation.TextMapPropagator satisfies it.
+type Tracer interface {
+	// Start starts a span as a child of the span in the context if any, returning the
+	// context holding the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Inject writes the trace context of the span in the context to the carrier
+	Inject(ctx context.Context, carrier TextMapCarrier)
+	// Extract returns the context with the remote trace context read from the carrier
+	Extract(ctx context.Context, carrier TextMapCarrier) context.Context
+}
+
+// NopTracer creates spans that record nothing
+type NopTracer struct{}
+
+// Start satisfies the Tracer interface
+func (NopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// Inject satisfies the Tracer interface
+func (NopTracer) Inject(ctx context.Context, carrier TextMapCarrier) {}
+
+// Extract satisfies the Tracer interface
+func (NopTracer) Extract(ctx context.Context, carrier TextMapCarrier) context.Context {
+	return ctx
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+func (nopSpan) RecordError(err error)                      {}
+func (nopSpan) End()                                       {}
+
+// endSpan records the error if any and ends the span
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// metadataCarrier is a TextMapCarrier over grpc metadata
+type metadataCarrier metadata.MD
+
+func (mc metadataCarrier) Get(key string) string {
+	if v := mc[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (mc metadataCarrier) Set(key, value string) {
+	mc[strings.ToLower(key)] = []string{value}
+}
+
+func (mc metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for k := range mc {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// injectTrace returns the context with the trace context added to the outgoing metadata
+func injectTrace(ctx context.Context, tracer Tracer) context.Context {
+	mc := metadataCarrier{}
+	tracer.Inject(ctx, mc)
+
+	kv := make([]string, 0, 2*len(mc))
+	for k, v := range mc {
+		kv = append(kv, k, v[0])
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// extractTrace returns the context with the remote trace context from the incoming
+// metadata
+func extractTrace(ctx context.Context, tracer Tracer) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return tracer.Extract(ctx, metadataCarrier(md))
+}
+
+// unaryTraceInterceptor creates a span for each outgoing unary call and propagates it
+func unaryTraceInterceptor(tracer Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"))
+		span.SetAttribute("peer", cc.Target())
+		if n := requestReplicas(method, req); n > 0 {
+			span.SetAttribute(LabelReplicas, n)
+		}
+
+		err := invoker(injectTrace(ctx, tracer), method, req, reply, cc, opts...)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// streamTraceInterceptor creates a span covering the opening of each outgoing stream and
+// propagates it
+func streamTraceInterceptor(tracer Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"))
+		span.SetAttribute("peer", cc.Target())
+
+		stream, err := streamer(injectTrace(ctx, tracer), desc, cc, method, opts...)
+		endSpan(span, err)
+		return stream, err
+	}
+}
+
+const traceparentHeader = "traceparent"
+
+// RecordedSpan is a span kept by a SpanRecorder
+type RecordedSpan struct {
+	Name     string
+	TraceID  string
+	SpanID   string
+	ParentID string
+	// Set if the parent span is in another process
+	RemoteParent bool
+	Attributes   map[string]interface{}
+	Err          error
+	Start        time.Time
+	End          time.Time
+}
+
+type spanContext struct {
+	traceID string
+	spanID  string
+}
+
+type spanContextKey struct{}
+type remoteSpanContextKey struct{}
+
+// SpanRecorder is a Tracer keeping ended spans in memory.  It propagates trace context
+// with the W3C traceparent header.  It is meant for tests and debugging.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewSpanRecorder instantiates a new SpanRecorder
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+// Start satisfies the Tracer interface
+func (sr *SpanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	rs := &RecordedSpan{
+		Name:       name,
+		SpanID:     randomHex(8),
+		Attributes: make(map[string]interface{}),
+		Start:      time.Now(),
+	}
+
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		rs.TraceID, rs.ParentID = parent.traceID, parent.spanID
+	} else if parent, ok := ctx.Value(remoteSpanContextKey{}).(spanContext); ok {
+		rs.TraceID, rs.ParentID, rs.RemoteParent = parent.traceID, parent.spanID, true
+	} else {
+		rs.TraceID = randomHex(16)
+	}
+
+	ctx = context.WithValue(ctx, spanContextKey{}, spanContext{traceID: rs.TraceID, spanID: rs.SpanID})
+	return ctx, &recorderSpan{recorder: sr, span: rs}
+}
+
+// Inject satisfies the Tracer interface
+func (sr *SpanRecorder) Inject(ctx context.Context, carrier TextMapCarrier) {
+	if sc, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		carrier.Set(traceparentHeader, "00-"+sc.traceID+"-"+sc.spanID+"-01")
+	}
+}
+
+// Extract satisfies the Tracer interface
+func (sr *SpanRecorder) Extract(ctx context.Context, carrier TextMapCarrier) context.Context {
+	parts := strings.Split(carrier.Get(traceparentHeader), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteSpanContextKey{}, spanContext{traceID: parts[1], spanID: parts[2]})
+}
+
+// Spans returns the ended spans in the order they ended
+func (sr *SpanRecorder) Spans() []*RecordedSpan {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return append([]*RecordedSpan(nil), sr.spans...)
+}
+
+// Reset discards all recorded spans
+func (sr *SpanRecorder) Reset() {
+	sr.mu.Lock()
+	sr.spans = nil
+	sr.mu.Unlock()
+}
+
+type recorderSpan struct {
+	recorder *SpanRecorder
+	span     *RecordedSpan
+	ended    bool
+}
+
+func (s *recorderSpan) SetAttribute(key string, value interface{}) {
+	s.recorder.mu.Lock()
+	s.span.Attributes[key] = value
+	s.recorder.mu.Unlock()
+}
+
+func (s *recorderSpan) RecordError(err error) {
+	s.recorder.mu.Lock()
+	s.span.Err = err
+	s.recorder.mu.Unlock()
+}
+
+func (s *recorderSpan) End() {
+	s.recorder.mu.Lock()
+	if !s.ended {
+		s.ended = true
+		s.span.End = time.Now()
+		s.recorder.spans = append(s.recorder.spans, s.span)
+	}
+	s.recorder.mu.Unlock()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
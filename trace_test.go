@@ -0,0 +1,154 @@
+package hexaring
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// testSpans returns the recorded spans by name
+func testSpans(sr *SpanRecorder) map[string][]*RecordedSpan {
+	out := map[string][]*RecordedSpan{}
+	for _, s := range sr.Spans() {
+		out[s.Name] = append(out[s.Name], s)
+	}
+	return out
+}
+
+func TestSpanRecorder(t *testing.T) {
+	sr := NewSpanRecorder()
+
+	ctx, root := sr.Start(context.Background(), "root")
+	_, child := sr.Start(ctx, "child")
+	child.SetAttribute("k", 1)
+	child.RecordError(fmt.Errorf("failed"))
+	child.End()
+	child.End()
+
+	// Propagate through metadata
+	md := metadata.MD{}
+	sr.Inject(ctx, metadataCarrier(md))
+	rctx := sr.Extract(context.Background(), metadataCarrier(md))
+	_, remote := sr.Start(rctx, "remote")
+	remote.End()
+	root.End()
+
+	spans := testSpans(sr)
+	if len(sr.Spans()) != 3 {
+		t.Fatal("should have 3 spans", len(sr.Spans()))
+	}
+	r, c, rm := spans["root"][0], spans["child"][0], spans["remote"][0]
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || c.RemoteParent {
+		t.Fatal("child should be a local child of root")
+	}
+	if c.Attributes["k"] != 1 || c.Err == nil {
+		t.Fatal("attributes and error not recorded")
+	}
+	if rm.TraceID != r.TraceID || rm.ParentID != r.SpanID || !rm.RemoteParent {
+		t.Fatal("remote should be a remote child of root", rm, r)
+	}
+	if r.ParentID != "" {
+		t.Fatal("root should not have a parent")
+	}
+
+	// Nothing to extract
+	if sr.Extract(context.Background(), metadataCarrier(metadata.MD{})).Value(remoteSpanContextKey{}) != nil {
+		t.Fatal("should not have a remote parent")
+	}
+
+	sr.Reset()
+	if len(sr.Spans()) != 0 {
+		t.Fatal("should be empty")
+	}
+}
+
+func TestNetClient_Tracer(t *testing.T) {
+	sr := NewSpanRecorder()
+
+	// Server side span continuing the client trace
+	ln, err := net.Listen("tcp", "127.0.0.1:17145")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := sr.Start(extractTrace(ctx, sr), "server")
+		defer span.End()
+		return handler(ctx, req)
+	}))
+	tree, _ := NewMerkleTree(testMerkleHash(0x00), testMerkleHash(0x80), 1)
+	NewMerkleService(func(start, end []byte, depth int) (*MerkleTree, error) {
+		return tree, nil
+	}).RegisterServer(server)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	client := NewNetClient(2*time.Second, 10*time.Second, WithTracer(sr), WithSigner(StaticTokenSigner("t")))
+	defer client.Shutdown()
+
+	ctx, root := sr.Start(context.Background(), "root")
+	if _, err = client.MerkleLevelCtx(ctx, "127.0.0.1:17145", &MerkleLevelRequest{Start: tree.start, End: tree.end, Depth: 1, Indexes: []int32{0}}); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	spans := testSpans(sr)
+	cs := spans["hexaring.MerkleRPC/MerkleLevelRPC"]
+	if len(cs) != 1 || len(spans["server"]) != 1 {
+		t.Fatal("should have client and server spans", spans)
+	}
+	ss := spans["server"][0]
+	if cs[0].ParentID != spans["root"][0].SpanID || cs[0].Attributes["peer"] != "127.0.0.1:17145" {
+		t.Fatal("client span should be a child of root", cs[0])
+	}
+	if ss.TraceID != cs[0].TraceID || ss.ParentID != cs[0].SpanID || !ss.RemoteParent {
+		t.Fatal("server span should continue the client span", ss, cs[0])
+	}
+}
+
+func TestRing_Tracer(t *testing.T) {
+	r, err := initTestRing("127.0.0.1:17146")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := NewSpanRecorder()
+	r.tracer = sr
+
+	client := NewNetClient(2*time.Second, 10*time.Second, WithTracer(sr))
+	defer client.Shutdown()
+	if _, err = client.LookupReplicated("127.0.0.1:17146", testkey, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := testSpans(sr)
+	var (
+		cs   = spans["hexaring.LookupRPC/LookupReplicatedRPC"]
+		ss   = spans["hexaring.LookupRPC/LookupReplicatedRPC"]
+		lk   = spans["Ring.LookupReplicatedHash"]
+		hops = spans["chord.LookupHash"]
+	)
+	if len(cs) != 2 || len(lk) != 1 || len(hops) == 0 {
+		t.Fatal("missing spans", spans)
+	}
+	// Client and server spans have the same name.  The server one has a remote parent
+	if cs[0].RemoteParent {
+		cs, ss = cs[1:], cs[:1]
+	} else {
+		ss = cs[1:]
+	}
+	if !ss[0].RemoteParent || ss[0].ParentID != cs[0].SpanID {
+		t.Fatal("server span should continue the client span")
+	}
+	if lk[0].ParentID != ss[0].SpanID || lk[0].Attributes[LabelReplicas] != 1 {
+		t.Fatal("lookup span should be a child of the server span")
+	}
+	for _, h := range hops {
+		if h.ParentID != lk[0].SpanID || h.TraceID != cs[0].TraceID {
+			t.Fatal("vertex lookup spans should be children of the lookup span")
+		}
+	}
+}